@@ -3,19 +3,26 @@ package backend
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net/url"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	awstypes "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/rizaldntr/storage-service-website-action/config"
 	"github.com/rizaldntr/storage-service-website-action/types"
 )
 
 type S3 struct {
 	client *s3.Client
+	sts    *sts.Client
 	bucket string
+	// omitACL is set for buckets that reject ACL headers.
+	omitACL bool
 }
 
 func NewS3(config config.Config) (*S3, error) {
@@ -26,8 +33,10 @@ func NewS3(config config.Config) (*S3, error) {
 
 	s3Client := s3.NewFromConfig(sdkConfig)
 	return &S3{
-		client: s3Client,
-		bucket: config.Bucket,
+		client:  s3Client,
+		sts:     sts.NewFromConfig(sdkConfig),
+		bucket:  config.Bucket,
+		omitACL: !config.ObjectOwnership.AllowsACLs(),
 	}, nil
 }
 
@@ -53,42 +62,242 @@ func (s *S3) GetObject(key string) ([]byte, error) {
 	return data, nil
 }
 
-func (s *S3) ListObjects(token *string) ([]string, error) {
-	result, err := s.client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
-		Bucket:            aws.String(s.bucket),
-		ContinuationToken: token,
+func (s *S3) HeadObject(key string) (types.ObjectInfo, error) {
+	result, err := s.client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
 	})
 	if err != nil {
-		return nil, err
+		var notFoundErr *awstypes.NotFound
+		if errors.As(err, &notFoundErr) {
+			return types.ObjectInfo{}, types.ObjectNotFoundError
+		}
+		return types.ObjectInfo{}, err
 	}
 
-	var keys []string
-	for _, obj := range result.Contents {
-		keys = append(keys, *obj.Key)
+	return types.ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(result.ContentLength),
+		ETag:         strings.Trim(aws.ToString(result.ETag), `"`),
+		LastModified: aws.ToTime(result.LastModified),
+		ContentType:  aws.ToString(result.ContentType),
+		CacheControl: aws.ToString(result.CacheControl),
+	}, nil
+}
+
+// ListObjects returns every object under prefix, following continuation
+// tokens until the listing is exhausted.
+func (s *S3) ListObjects(prefix string) ([]types.ObjectInfo, error) {
+	params := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	}
+	if prefix != "" {
+		params.Prefix = aws.String(prefix)
 	}
 
-	return keys, nil
+	var objects []types.ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(s.client, params)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range page.Contents {
+			objects = append(objects, types.ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				ETag:         strings.Trim(aws.ToString(obj.ETag), `"`),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+// ListObjectVersions returns every version and delete marker under prefix.
+func (s *S3) ListObjectVersions(prefix string) ([]types.ObjectVersion, error) {
+	params := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(s.bucket),
+	}
+	if prefix != "" {
+		params.Prefix = aws.String(prefix)
+	}
+
+	var versions []types.ObjectVersion
+	for {
+		page, err := s.client.ListObjectVersions(context.TODO(), params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range page.Versions {
+			versions = append(versions, types.ObjectVersion{
+				Key:          aws.ToString(v.Key),
+				VersionID:    aws.ToString(v.VersionId),
+				LastModified: aws.ToTime(v.LastModified),
+				IsLatest:     aws.ToBool(v.IsLatest),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			versions = append(versions, types.ObjectVersion{
+				Key:            aws.ToString(m.Key),
+				VersionID:      aws.ToString(m.VersionId),
+				LastModified:   aws.ToTime(m.LastModified),
+				IsLatest:       aws.ToBool(m.IsLatest),
+				IsDeleteMarker: true,
+			})
+		}
+
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		params.KeyMarker = page.NextKeyMarker
+		params.VersionIdMarker = page.NextVersionIdMarker
+	}
+
+	return versions, nil
+}
+
+// CopyObject makes request.VersionID the current version of request.Key,
+// keeping the metadata stored with that version.
+func (s *S3) CopyObject(request types.CopyObjectRequest) error {
+	segments := strings.Split(request.Key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	source := s.bucket + "/" + strings.Join(segments, "/") + "?versionId=" + url.QueryEscape(request.VersionID)
+
+	_, err := s.client.CopyObject(context.TODO(), &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(request.Key),
+		CopySource: aws.String(source),
+		ACL:        s.cannedACL(request.ACL),
+	})
+	return err
 }
 
 func (s *S3) PutObject(request types.PutObjectRequest) error {
-	_, err := s.client.PutObject(context.TODO(), &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		Bucket:       aws.String(s.bucket),
 		Key:          aws.String(request.Key),
 		Body:         request.Body,
 		CacheControl: aws.String(request.CacheControl),
 		ContentType:  aws.String(request.ContentType),
-		ACL: func() awstypes.ObjectCannedACL {
-			if request.ACL == types.PublicACL {
-				return awstypes.ObjectCannedACLPublicRead
-			}
-			return awstypes.ObjectCannedACLPrivate
-		}(),
-	})
+		ACL:          s.cannedACL(request.ACL),
+	}
+	if request.ContentMD5 != "" {
+		input.ContentMD5 = aws.String(request.ContentMD5)
+	}
+	if request.WebsiteRedirectLocation != "" {
+		input.WebsiteRedirectLocation = aws.String(request.WebsiteRedirectLocation)
+	}
+	switch request.ChecksumAlgorithm {
+	case types.SHA256:
+		input.ChecksumAlgorithm = awstypes.ChecksumAlgorithmSha256
+		input.ChecksumSHA256 = aws.String(request.Checksum)
+	case types.CRC32C:
+		input.ChecksumAlgorithm = awstypes.ChecksumAlgorithmCrc32c
+		input.ChecksumCRC32C = aws.String(request.Checksum)
+	}
+
+	result, err := s.client.PutObject(context.TODO(), input)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return verifyChecksum(request.ChecksumAlgorithm, request.Checksum, result.ChecksumSHA256, result.ChecksumCRC32C)
+}
+
+// CreateMultipartUpload starts a multipart upload using the metadata of
+// request and returns its upload ID. The request body is ignored.
+func (s *S3) CreateMultipartUpload(request types.PutObjectRequest) (string, error) {
+	result, err := s.client.CreateMultipartUpload(context.TODO(), &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               aws.String(request.Key),
+		CacheControl:      aws.String(request.CacheControl),
+		ContentType:       aws.String(request.ContentType),
+		ACL:               s.cannedACL(request.ACL),
+		ChecksumAlgorithm: checksumAlgorithm(request.ChecksumAlgorithm),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(result.UploadId), nil
+}
+
+func (s *S3) UploadPart(request types.UploadPartRequest) (types.CompletedPart, error) {
+	input := &s3.UploadPartInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(request.Key),
+		UploadId:      aws.String(request.UploadID),
+		PartNumber:    aws.Int32(request.PartNumber),
+		Body:          request.Body,
+		ContentLength: aws.Int64(request.Size),
+	}
+	if request.ContentMD5 != "" {
+		input.ContentMD5 = aws.String(request.ContentMD5)
+	}
+	switch request.ChecksumAlgorithm {
+	case types.SHA256:
+		input.ChecksumAlgorithm = awstypes.ChecksumAlgorithmSha256
+		input.ChecksumSHA256 = aws.String(request.Checksum)
+	case types.CRC32C:
+		input.ChecksumAlgorithm = awstypes.ChecksumAlgorithmCrc32c
+		input.ChecksumCRC32C = aws.String(request.Checksum)
+	}
+
+	result, err := s.client.UploadPart(context.TODO(), input)
+	if err != nil {
+		return types.CompletedPart{}, err
+	}
+	if err := verifyChecksum(request.ChecksumAlgorithm, request.Checksum, result.ChecksumSHA256, result.ChecksumCRC32C); err != nil {
+		return types.CompletedPart{}, err
+	}
+
+	return types.CompletedPart{
+		PartNumber: request.PartNumber,
+		ETag:       aws.ToString(result.ETag),
+		Checksum:   request.Checksum,
+	}, nil
+}
+
+func (s *S3) CompleteMultipartUpload(key, uploadID string, algorithm types.HashAlgorithm, parts []types.CompletedPart) error {
+	completed := make([]awstypes.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		p := awstypes.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.PartNumber),
+		}
+		switch algorithm {
+		case types.SHA256:
+			p.ChecksumSHA256 = aws.String(part.Checksum)
+		case types.CRC32C:
+			p.ChecksumCRC32C = aws.String(part.Checksum)
+		}
+		completed = append(completed, p)
+	}
+
+	_, err := s.client.CompleteMultipartUpload(context.TODO(), &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+		MultipartUpload: &awstypes.CompletedMultipartUpload{
+			Parts: completed,
+		},
+	})
+	return err
+}
+
+func (s *S3) AbortMultipartUpload(key, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return err
 }
 
 func (s *S3) DeleteObject(key string) error {
@@ -171,3 +380,40 @@ func (s *S3) EmptyBucket() error {
 
 	return nil
 }
+
+// cannedACL converts acl to its header value, empty values are not sent.
+func (s *S3) cannedACL(acl types.ObjectACL) awstypes.ObjectCannedACL {
+	if s.omitACL || acl == types.NoACL {
+		return ""
+	}
+	return awstypes.ObjectCannedACL(acl)
+}
+
+func checksumAlgorithm(algorithm types.HashAlgorithm) awstypes.ChecksumAlgorithm {
+	switch algorithm {
+	case types.SHA256:
+		return awstypes.ChecksumAlgorithmSha256
+	case types.CRC32C:
+		return awstypes.ChecksumAlgorithmCrc32c
+	}
+	return ""
+}
+
+// verifyChecksum makes sure the checksum computed by S3 over the received
+// body is the one that was sent.
+func verifyChecksum(algorithm types.HashAlgorithm, sent string, sha256, crc32c *string) error {
+	var received *string
+	switch algorithm {
+	case types.SHA256:
+		received = sha256
+	case types.CRC32C:
+		received = crc32c
+	default:
+		return nil
+	}
+
+	if aws.ToString(received) != sent {
+		return fmt.Errorf("%s checksum mismatch: sent %s, stored %s", algorithm, sent, aws.ToString(received))
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package backend
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// GetBucketWebsite returns the website configuration of the bucket, or nil
+// when website hosting is not enabled.
+func (s *S3) GetBucketWebsite() (*types.WebsiteConfig, error) {
+	result, err := s.client.GetBucketWebsite(context.TODO(), &s3.GetBucketWebsiteInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		if isErrorCode(err, "NoSuchWebsiteConfiguration") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	website := &types.WebsiteConfig{}
+	if result.IndexDocument != nil {
+		website.IndexDocument = aws.ToString(result.IndexDocument.Suffix)
+	}
+	if result.ErrorDocument != nil {
+		website.ErrorDocument = aws.ToString(result.ErrorDocument.Key)
+	}
+	for _, rule := range result.RoutingRules {
+		var r types.RoutingRule
+		if rule.Condition != nil {
+			r.Condition = &types.RoutingCondition{
+				KeyPrefixEquals:             aws.ToString(rule.Condition.KeyPrefixEquals),
+				HTTPErrorCodeReturnedEquals: aws.ToString(rule.Condition.HttpErrorCodeReturnedEquals),
+			}
+		}
+		if rule.Redirect != nil {
+			r.Redirect = types.RoutingRedirect{
+				HostName:             aws.ToString(rule.Redirect.HostName),
+				HTTPRedirectCode:     aws.ToString(rule.Redirect.HttpRedirectCode),
+				Protocol:             string(rule.Redirect.Protocol),
+				ReplaceKeyPrefixWith: aws.ToString(rule.Redirect.ReplaceKeyPrefixWith),
+				ReplaceKeyWith:       aws.ToString(rule.Redirect.ReplaceKeyWith),
+			}
+		}
+		website.RoutingRules = append(website.RoutingRules, r)
+	}
+	return website, nil
+}
+
+func (s *S3) PutBucketWebsite(website types.WebsiteConfig) error {
+	config := &awstypes.WebsiteConfiguration{
+		IndexDocument: &awstypes.IndexDocument{Suffix: aws.String(website.IndexDocument)},
+	}
+	if website.ErrorDocument != "" {
+		config.ErrorDocument = &awstypes.ErrorDocument{Key: aws.String(website.ErrorDocument)}
+	}
+	for _, rule := range website.RoutingRules {
+		r := awstypes.RoutingRule{
+			Redirect: &awstypes.Redirect{
+				HostName:             optionalString(rule.Redirect.HostName),
+				HttpRedirectCode:     optionalString(rule.Redirect.HTTPRedirectCode),
+				Protocol:             awstypes.Protocol(rule.Redirect.Protocol),
+				ReplaceKeyPrefixWith: optionalString(rule.Redirect.ReplaceKeyPrefixWith),
+				ReplaceKeyWith:       optionalString(rule.Redirect.ReplaceKeyWith),
+			},
+		}
+		if rule.Condition != nil {
+			r.Condition = &awstypes.Condition{
+				KeyPrefixEquals:             optionalString(rule.Condition.KeyPrefixEquals),
+				HttpErrorCodeReturnedEquals: optionalString(rule.Condition.HTTPErrorCodeReturnedEquals),
+			}
+		}
+		config.RoutingRules = append(config.RoutingRules, r)
+	}
+
+	_, err := s.client.PutBucketWebsite(context.TODO(), &s3.PutBucketWebsiteInput{
+		Bucket:               aws.String(s.bucket),
+		WebsiteConfiguration: config,
+	})
+	return err
+}
+
+// AccountID returns the AWS account of the credentials in use.
+func (s *S3) AccountID() (string, error) {
+	result, err := s.sts.GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(result.Account), nil
+}
+
+// GetBucketPolicy returns the policy document of the bucket, or an empty
+// string when it has none.
+func (s *S3) GetBucketPolicy() (string, error) {
+	result, err := s.client.GetBucketPolicy(context.TODO(), &s3.GetBucketPolicyInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		if isErrorCode(err, "NoSuchBucketPolicy") {
+			return "", nil
+		}
+		return "", err
+	}
+	return aws.ToString(result.Policy), nil
+}
+
+func (s *S3) PutBucketPolicy(policy string) error {
+	_, err := s.client.PutBucketPolicy(context.TODO(), &s3.PutBucketPolicyInput{
+		Bucket: aws.String(s.bucket),
+		Policy: aws.String(policy),
+	})
+	return err
+}
+
+// GetBucketCORS returns the CORS rules of the bucket, nil when it has none.
+func (s *S3) GetBucketCORS() ([]types.CORSRule, error) {
+	result, err := s.client.GetBucketCors(context.TODO(), &s3.GetBucketCorsInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		if isErrorCode(err, "NoSuchCORSConfiguration") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rules []types.CORSRule
+	for _, rule := range result.CORSRules {
+		rules = append(rules, types.CORSRule{
+			AllowedOrigins: rule.AllowedOrigins,
+			AllowedMethods: rule.AllowedMethods,
+			AllowedHeaders: rule.AllowedHeaders,
+			ExposeHeaders:  rule.ExposeHeaders,
+			MaxAgeSeconds:  int(aws.ToInt32(rule.MaxAgeSeconds)),
+		})
+	}
+	return rules, nil
+}
+
+func (s *S3) PutBucketCORS(rules []types.CORSRule) error {
+	corsRules := make([]awstypes.CORSRule, 0, len(rules))
+	for _, rule := range rules {
+		r := awstypes.CORSRule{
+			AllowedOrigins: rule.AllowedOrigins,
+			AllowedMethods: rule.AllowedMethods,
+			AllowedHeaders: rule.AllowedHeaders,
+			ExposeHeaders:  rule.ExposeHeaders,
+		}
+		if rule.MaxAgeSeconds > 0 {
+			r.MaxAgeSeconds = aws.Int32(int32(rule.MaxAgeSeconds))
+		}
+		corsRules = append(corsRules, r)
+	}
+
+	_, err := s.client.PutBucketCors(context.TODO(), &s3.PutBucketCorsInput{
+		Bucket:            aws.String(s.bucket),
+		CORSConfiguration: &awstypes.CORSConfiguration{CORSRules: corsRules},
+	})
+	return err
+}
+
+func isErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
+
+// optionalString omits empty values from requests.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
@@ -0,0 +1,116 @@
+package cdn
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+)
+
+const (
+	cloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+	// cloudflareBatchSize is the number of URLs or prefixes accepted by one
+	// purge request.
+	cloudflareBatchSize = 30
+)
+
+// Cloudflare purges URLs of a Cloudflare zone.
+type Cloudflare struct {
+	endpoint string
+	zone     string
+	token    string
+	baseURL  string
+}
+
+func NewCloudflare(config config.CDNConfig) *Cloudflare {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = cloudflareEndpoint
+	}
+	return &Cloudflare{
+		endpoint: endpoint,
+		zone:     config.ID,
+		token:    config.Token,
+		baseURL:  config.BaseURL,
+	}
+}
+
+type cloudflarePurge struct {
+	Files           []string `json:"files,omitempty"`
+	Prefixes        []string `json:"prefixes,omitempty"`
+	PurgeEverything bool     `json:"purge_everything,omitempty"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result struct {
+		ID string `json:"id"`
+	} `json:"result"`
+}
+
+// Invalidate purges the URLs of paths below the base URL. Wildcard paths
+// are purged as prefixes, and /* purges the whole zone.
+func (c *Cloudflare) Invalidate(reference string, paths []string) (string, error) {
+	var files, prefixes []string
+	for _, path := range paths {
+		switch {
+		case path == "/*":
+			return c.purge(cloudflarePurge{PurgeEverything: true})
+		case strings.HasSuffix(path, "*"):
+			// prefixes are given without the scheme
+			prefix := c.baseURL + strings.TrimSuffix(path, "*")
+			prefixes = append(prefixes, prefix[strings.Index(prefix, "://")+3:])
+		default:
+			files = append(files, c.baseURL+path)
+		}
+	}
+
+	var id string
+	for len(files) > 0 || len(prefixes) > 0 {
+		var request cloudflarePurge
+		request.Files, files = batch(files, cloudflareBatchSize)
+		if len(request.Files) == 0 {
+			request.Prefixes, prefixes = batch(prefixes, cloudflareBatchSize)
+		}
+		var err error
+		if id, err = c.purge(request); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// Wait returns at once, Cloudflare purges are applied when acknowledged.
+func (c *Cloudflare) Wait(id string) error {
+	return nil
+}
+
+func (c *Cloudflare) purge(request cloudflarePurge) (string, error) {
+	var response cloudflareResponse
+	err := doJSON(http.MethodPost, fmt.Sprintf("%s/zones/%s/purge_cache", c.endpoint, url.PathEscape(c.zone)),
+		map[string]string{"Authorization": "Bearer " + c.token}, request, &response)
+	if len(response.Errors) > 0 {
+		return "", fmt.Errorf("Error purging Cloudflare cache: %s (%d)", response.Errors[0].Message, response.Errors[0].Code)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Error purging Cloudflare cache: %v", err)
+	}
+	if !response.Success {
+		return "", fmt.Errorf("Error purging Cloudflare cache: request was not successful")
+	}
+	return response.Result.ID, nil
+}
+
+// batch splits the first n items off items.
+func batch(items []string, n int) ([]string, []string) {
+	if len(items) <= n {
+		return items, nil
+	}
+	return items[:n], items[n:]
+}
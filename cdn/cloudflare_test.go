@@ -0,0 +1,103 @@
+package cdn
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+)
+
+func TestCloudflareInvalidate(t *testing.T) {
+	var requests []cloudflarePurge
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/zones/zone1/purge_cache" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var request cloudflarePurge
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, request)
+		fmt.Fprintf(w, `{"success":true,"errors":[],"result":{"id":"purge-%d"}}`, len(requests))
+	}))
+	defer server.Close()
+	client := NewCloudflare(config.CDNConfig{Endpoint: server.URL, ID: "zone1", Token: "token", BaseURL: "https://example.com"})
+
+	var paths []string
+	for i := 0; i < 35; i++ {
+		paths = append(paths, fmt.Sprintf("/%02d.html", i))
+	}
+	paths = append(paths, "/docs/*", "/blog/*")
+	id, err := client.Invalidate("ref", paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "purge-3" {
+		t.Errorf("id = %q, want the last purge", id)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(requests))
+	}
+	if len(requests[0].Files) != 30 || len(requests[1].Files) != 5 {
+		t.Errorf("batches of %d and %d files, want 30 and 5", len(requests[0].Files), len(requests[1].Files))
+	}
+	if requests[0].Files[0] != "https://example.com/00.html" {
+		t.Errorf("file = %q", requests[0].Files[0])
+	}
+	if want := []string{"example.com/docs/", "example.com/blog/"}; !reflect.DeepEqual(requests[2].Prefixes, want) {
+		t.Errorf("prefixes = %v, want %v", requests[2].Prefixes, want)
+	}
+}
+
+func TestCloudflareInvalidateEverything(t *testing.T) {
+	var request cloudflarePurge
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		fmt.Fprint(w, `{"success":true,"result":{"id":"all"}}`)
+	}))
+	defer server.Close()
+	client := NewCloudflare(config.CDNConfig{Endpoint: server.URL, ID: "zone1", BaseURL: "https://example.com"})
+
+	if _, err := client.Invalidate("ref", []string{"/a.html", "/*"}); err != nil {
+		t.Fatal(err)
+	}
+	if !request.PurgeEverything || len(request.Files) > 0 {
+		t.Errorf("request = %+v, want purge_everything only", request)
+	}
+}
+
+func TestCloudflareInvalidateError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"api error", http.StatusBadRequest, `{"success":false,"errors":[{"code":1012,"message":"Request must contain one of files"}]}`, "Request must contain one of files (1012)"},
+		{"status", http.StatusInternalServerError, ``, "unexpected status 500"},
+		{"unsuccessful", http.StatusOK, `{"success":false}`, "not successful"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+			client := NewCloudflare(config.CDNConfig{Endpoint: server.URL, ID: "zone1", BaseURL: "https://example.com"})
+
+			_, err := client.Invalidate("ref", []string{"/a.html"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
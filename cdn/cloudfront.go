@@ -0,0 +1,164 @@
+// Package cdn invalidates cached paths of the CDNs serving the bucket. The
+// clients talk to the REST API of each provider, so they can be pointed at a
+// local HTTP stub through the endpoint setting.
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/rizaldntr/storage-service-website-action/config"
+)
+
+const (
+	cloudFrontEndpoint = "https://cloudfront.amazonaws.com"
+	cloudFrontVersion  = "2020-05-31"
+	// CloudFront is a global service signed for us-east-1.
+	cloudFrontRegion = "us-east-1"
+)
+
+// CloudFront invalidates paths of a CloudFront distribution.
+type CloudFront struct {
+	client       *http.Client
+	credentials  aws.CredentialsProvider
+	signer       *v4.Signer
+	endpoint     string
+	distribution string
+	// PollInterval and Timeout bound Wait.
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+func NewCloudFront(config config.CDNConfig) (*CloudFront, error) {
+	sdkConfig, err := awsconfig.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = cloudFrontEndpoint
+	}
+	return &CloudFront{
+		client:       &http.Client{Timeout: time.Minute},
+		credentials:  sdkConfig.Credentials,
+		signer:       v4.NewSigner(),
+		endpoint:     endpoint,
+		distribution: config.ID,
+		PollInterval: 10 * time.Second,
+		Timeout:      20 * time.Minute,
+	}, nil
+}
+
+type invalidationBatch struct {
+	XMLName         xml.Name `xml:"http://cloudfront.amazonaws.com/doc/2020-05-31/ InvalidationBatch"`
+	Quantity        int      `xml:"Paths>Quantity"`
+	Paths           []string `xml:"Paths>Items>Path"`
+	CallerReference string   `xml:"CallerReference"`
+}
+
+type invalidation struct {
+	ID     string `xml:"Id"`
+	Status string `xml:"Status"`
+}
+
+type cloudFrontError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// Invalidate creates an invalidation of paths. The reference identifies the
+// request, retrying with the same one does not create a second invalidation.
+func (c *CloudFront) Invalidate(reference string, paths []string) (string, error) {
+	body, err := xml.Marshal(invalidationBatch{
+		Quantity:        len(paths),
+		Paths:           paths,
+		CallerReference: reference,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result invalidation
+	if err := c.do(http.MethodPost, c.invalidationURL(""), body, &result); err != nil {
+		return "", fmt.Errorf("Error creating CloudFront invalidation: %v", err)
+	}
+	return result.ID, nil
+}
+
+// Wait polls the invalidation until CloudFront reports it completed.
+func (c *CloudFront) Wait(id string) error {
+	deadline := time.Now().Add(c.Timeout)
+	for {
+		var result invalidation
+		if err := c.do(http.MethodGet, c.invalidationURL(id), nil, &result); err != nil {
+			return fmt.Errorf("Error reading CloudFront invalidation %s: %v", id, err)
+		}
+		if result.Status == "Completed" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("CloudFront invalidation %s still %s after %s", id, result.Status, c.Timeout)
+		}
+		time.Sleep(c.PollInterval)
+	}
+}
+
+func (c *CloudFront) invalidationURL(id string) string {
+	u := fmt.Sprintf("%s/%s/distribution/%s/invalidation", c.endpoint, cloudFrontVersion, url.PathEscape(c.distribution))
+	if id != "" {
+		u += "/" + url.PathEscape(id)
+	}
+	return u
+}
+
+// do sends a request signed with Signature Version 4 and decodes the XML
+// response into result.
+func (c *CloudFront) do(method, url string, body []byte, result any) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+
+	ctx := context.TODO()
+	credentials, err := c.credentials.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(body)
+	if err := c.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "cloudfront", cloudFrontRegion, time.Now()); err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr cloudFrontError
+		if xml.Unmarshal(data, &apiErr) == nil && apiErr.Code != "" {
+			return fmt.Errorf("%s: %s", apiErr.Code, apiErr.Message)
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return xml.Unmarshal(data, result)
+}
@@ -0,0 +1,104 @@
+package cdn
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+)
+
+func newTestCloudFront(t *testing.T, handler http.HandlerFunc) *CloudFront {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewCloudFront(config.CDNConfig{Endpoint: server.URL, ID: "E123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.PollInterval = time.Millisecond
+	return client
+}
+
+func TestCloudFrontInvalidate(t *testing.T) {
+	var batch invalidationBatch
+	client := newTestCloudFront(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/2020-05-31/distribution/E123/invalidation" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/us-east-1/cloudfront/") {
+			t.Errorf("Authorization = %q", auth)
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `<Invalidation><Id>I1</Id><Status>InProgress</Status></Invalidation>`)
+	})
+
+	id, err := client.Invalidate("deploy-1", []string{"/index.html", "/docs/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "I1" {
+		t.Errorf("id = %q, want I1", id)
+	}
+	if batch.CallerReference != "deploy-1" || batch.Quantity != 2 || !reflect.DeepEqual(batch.Paths, []string{"/index.html", "/docs/*"}) {
+		t.Errorf("batch = %+v", batch)
+	}
+}
+
+func TestCloudFrontInvalidateError(t *testing.T) {
+	client := newTestCloudFront(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `<ErrorResponse><Error><Code>TooManyInvalidationsInProgress</Code><Message>Slow down</Message></Error></ErrorResponse>`)
+	})
+
+	_, err := client.Invalidate("deploy-1", []string{"/index.html"})
+	if err == nil || !strings.Contains(err.Error(), "TooManyInvalidationsInProgress: Slow down") {
+		t.Errorf("err = %v, want the code and message of the response", err)
+	}
+}
+
+func TestCloudFrontWait(t *testing.T) {
+	polls := 0
+	client := newTestCloudFront(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/2020-05-31/distribution/E123/invalidation/I1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		polls++
+		status := "InProgress"
+		if polls == 3 {
+			status = "Completed"
+		}
+		fmt.Fprintf(w, `<Invalidation><Id>I1</Id><Status>%s</Status></Invalidation>`, status)
+	})
+
+	if err := client.Wait("I1"); err != nil {
+		t.Fatal(err)
+	}
+	if polls != 3 {
+		t.Errorf("polled %d times, want 3", polls)
+	}
+}
+
+func TestCloudFrontWaitTimeout(t *testing.T) {
+	client := newTestCloudFront(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<Invalidation><Id>I1</Id><Status>InProgress</Status></Invalidation>`)
+	})
+	client.Timeout = 5 * time.Millisecond
+
+	err := client.Wait("I1")
+	if err == nil || !strings.Contains(err.Error(), "still InProgress") {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,78 @@
+package cdn
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+)
+
+const fastlyEndpoint = "https://api.fastly.com"
+
+// Fastly purges URLs of a Fastly service.
+type Fastly struct {
+	endpoint string
+	service  string
+	token    string
+	baseURL  string
+}
+
+func NewFastly(config config.CDNConfig) *Fastly {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = fastlyEndpoint
+	}
+	return &Fastly{
+		endpoint: endpoint,
+		service:  config.ID,
+		token:    config.Token,
+		baseURL:  config.BaseURL,
+	}
+}
+
+type fastlyResponse struct {
+	Status string `json:"status"`
+	ID     string `json:"id"`
+	Msg    string `json:"msg"`
+}
+
+// Invalidate purges the URLs of paths one by one. Fastly cannot purge by
+// prefix, so any wildcard purges the whole service.
+func (c *Fastly) Invalidate(reference string, paths []string) (string, error) {
+	for _, path := range paths {
+		if strings.HasSuffix(path, "*") {
+			return c.purge(fmt.Sprintf("%s/service/%s/purge_all", c.endpoint, url.PathEscape(c.service)))
+		}
+	}
+
+	var id string
+	for _, path := range paths {
+		// the URL is given without the scheme
+		target := c.baseURL + path
+		target = target[strings.Index(target, "://")+3:]
+		var err error
+		if id, err = c.purge(c.endpoint + "/purge/" + target); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// Wait returns at once, Fastly purges complete within seconds.
+func (c *Fastly) Wait(id string) error {
+	return nil
+}
+
+func (c *Fastly) purge(url string) (string, error) {
+	var response fastlyResponse
+	err := doJSON(http.MethodPost, url, map[string]string{"Fastly-Key": c.token}, nil, &response)
+	if err != nil {
+		if response.Msg != "" {
+			return "", fmt.Errorf("Error purging Fastly cache: %s", response.Msg)
+		}
+		return "", fmt.Errorf("Error purging Fastly cache: %v", err)
+	}
+	return response.ID, nil
+}
@@ -0,0 +1,65 @@
+package cdn
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+)
+
+func TestFastlyInvalidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{"urls", []string{"/a.html", "/docs/"}, []string{"/purge/example.com/a.html", "/purge/example.com/docs/"}},
+		{"wildcard", []string{"/a.html", "/docs/*"}, []string{"/service/svc1/purge_all"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("method = %s", r.Method)
+				}
+				if key := r.Header.Get("Fastly-Key"); key != "token" {
+					t.Errorf("Fastly-Key = %q", key)
+				}
+				got = append(got, r.URL.Path)
+				fmt.Fprintf(w, `{"status":"ok","id":"purge-%d"}`, len(got))
+			}))
+			defer server.Close()
+			client := NewFastly(config.CDNConfig{Endpoint: server.URL, ID: "svc1", Token: "token", BaseURL: "https://example.com"})
+
+			id, err := client.Invalidate("ref", tt.paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requests = %v, want %v", got, tt.want)
+			}
+			if want := fmt.Sprintf("purge-%d", len(tt.want)); id != want {
+				t.Errorf("id = %q, want %q", id, want)
+			}
+		})
+	}
+}
+
+func TestFastlyInvalidateError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"msg":"Provided credentials are missing or invalid"}`)
+	}))
+	defer server.Close()
+	client := NewFastly(config.CDNConfig{Endpoint: server.URL, ID: "svc1", BaseURL: "https://example.com"})
+
+	_, err := client.Invalidate("ref", []string{"/a.html"})
+	if err == nil || !strings.Contains(err.Error(), "credentials are missing") {
+		t.Errorf("err = %v, want the message of the response", err)
+	}
+}
@@ -0,0 +1,251 @@
+// Package cli implements the command line interface used outside of GitHub
+// Actions. Every command builds the same config.Config the action does.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+)
+
+const usage = `Deploy static websites to S3.
+
+Usage:
+  storage-service-website <command> [flags]
+
+Commands:
+  deploy            Upload the folder and remove leftover objects
+  plan              Show what deploy would do without writing anything
+  verify            Report drift between the bucket, the manifest and the folder
+  rollback          Restore a previous deploy (requires bucket versioning)
+  manifest show     Print the .incremental manifest stored in the bucket
+  ls [prefix]       List the objects of the bucket
+  preview deploy    Deploy the folder as the preview of a pull request
+  preview cleanup   Remove the preview of a pull request
+  preview gc        Remove previews not deployed to within the max age
+
+Settings are read from, in order of precedence: flags, environment
+variables (BUCKET, FOLDER, ...), the file given with --config or CONFIG
+and the built-in defaults. Run "<command> -h" for the flags of a command.
+`
+
+const (
+	// exitDrift is returned by verify when drift is found, to tell it apart
+	// from a failure.
+	exitDrift = 2
+	// exitUsage is returned for an invalid command line, EX_USAGE.
+	exitUsage = 64
+)
+
+type command struct {
+	flags *flag.FlagSet
+	run   func(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error)
+	// options is filled in by flags
+	options *options
+}
+
+type options struct {
+	configFile  string
+	environment string
+	bucket      string
+	prefix      string
+	folder      string
+	syncMode    string
+	exclude     listFlag
+	set         listFlag
+	json        bool
+	verbose     bool
+	to          string
+	list        bool
+	pr          string
+}
+
+// listFlag collects every occurrence of a repeatable flag.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Run executes the command named by args[0] and returns the exit status.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stderr, usage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return 0
+	}
+
+	name := args[0]
+	args = args[1:]
+	if subcommands, ok := groups[name]; ok {
+		if len(args) == 0 || !contains(subcommands, args[0]) {
+			fmt.Fprintf(stderr, "unknown command, expected %s %s\n", name, strings.Join(subcommands, "|"))
+			return exitUsage
+		}
+		name += " " + args[0]
+		args = args[1:]
+	}
+
+	cmd, ok := commands()[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", name, usage)
+		return exitUsage
+	}
+
+	cmd.flags.SetOutput(stderr)
+	if err := cmd.flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitUsage
+	}
+
+	logger.SetAction(false)
+	logger.SetVerbose(cmd.options.verbose)
+
+	cfg, err := loadConfig(cmd.options)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	code, err := cmd.run(cfg, cmd.options, cmd.flags.Args(), stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// groups lists the commands made of a group and a subcommand.
+var groups = map[string][]string{
+	"manifest": {"show"},
+	"preview":  {"deploy", "cleanup", "gc"},
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func commands() map[string]*command {
+	return map[string]*command{
+		"deploy":          newCommand("deploy", runDeploy),
+		"plan":            newCommand("plan", runPlan, withJSON),
+		"verify":          newCommand("verify", runVerify, withJSON),
+		"rollback":        newCommand("rollback", runRollback, withRollback),
+		"manifest show":   newCommand("manifest show", runManifestShow, withJSON),
+		"ls":              newCommand("ls", runList, withJSON),
+		"preview deploy":  newCommand("preview deploy", runPreviewDeploy, withJSON, withPreview),
+		"preview cleanup": newCommand("preview cleanup", runPreviewCleanup, withPreview),
+		"preview gc":      newCommand("preview gc", runPreviewGC),
+	}
+}
+
+func newCommand(name string, run func(config.Config, *options, []string, io.Writer) (int, error), extra ...func(*flag.FlagSet, *options)) *command {
+	opts := &options{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&opts.configFile, "config", "", "read settings from a YAML or JSON `file`")
+	flags.StringVar(&opts.environment, "environment", "", "use the settings of the environment `name` from the config file")
+	flags.StringVar(&opts.bucket, "bucket", "", "target bucket")
+	flags.StringVar(&opts.prefix, "prefix", "", "deploy under this key prefix of the bucket")
+	flags.StringVar(&opts.folder, "folder", "", "local folder to deploy")
+	flags.StringVar(&opts.syncMode, "sync-mode", "", "manifest or remote")
+	flags.Var(&opts.exclude, "exclude", "exclude files matching `pattern` (repeatable)")
+	flags.Var(&opts.set, "set", "set any action input, e.g. --set html-cache-control=no-cache (repeatable)")
+	flags.BoolVar(&opts.verbose, "verbose", false, "print debug messages")
+	for _, e := range extra {
+		e(flags, opts)
+	}
+	return &command{flags: flags, run: run, options: opts}
+}
+
+func withJSON(flags *flag.FlagSet, opts *options) {
+	flags.BoolVar(&opts.json, "json", false, "print machine readable JSON")
+}
+
+func withPreview(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.pr, "pr", "", "pull request `number`, read from GITHUB_EVENT_PATH when omitted")
+}
+
+func withRollback(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.to, "to", "", "deploy `id` to restore, defaults to the one before the latest")
+	flags.BoolVar(&opts.list, "list", false, "list recorded deploys instead of rolling back")
+}
+
+// loadConfig layers the flags over the environment over the config file.
+func loadConfig(opts *options) (config.Config, error) {
+	godotenv.Load(".env")
+
+	flags := config.Values{
+		"ENVIRONMENT": opts.environment,
+		"BUCKET":      opts.bucket,
+		"PREFIX":      opts.prefix,
+		"FOLDER":      opts.folder,
+		"SYNC_MODE":   opts.syncMode,
+		"EXCLUDE":     strings.Join(opts.exclude, "\n"),
+		"PR_NUMBER":   opts.pr,
+	}
+	for _, s := range opts.set {
+		input, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return config.Config{}, fmt.Errorf("invalid --set %q, expected input=value", s)
+		}
+		flags[config.KeyOf(input)] = value
+	}
+	overrides := config.EnvValues().Merge(flags)
+
+	configFile := opts.configFile
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG")
+	}
+	values := config.Values{}
+	if configFile != "" {
+		file, err := config.FileValues(configFile, overrides["ENVIRONMENT"])
+		if err != nil {
+			return config.Config{}, err
+		}
+		values = file
+	}
+
+	cfg, err := config.Load(values.Merge(overrides))
+	if err != nil {
+		return config.Config{}, err
+	}
+	if cfg.Bucket == "" {
+		return config.Config{}, fmt.Errorf("no bucket given, use --bucket, BUCKET or --config")
+	}
+	return cfg, nil
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Main runs the CLI with the process arguments and exits.
+func Main() {
+	os.Exit(Run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/core"
+)
+
+func runDeploy(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error) {
+	stats, err := core.Process(cfg)
+	if err != nil {
+		return 1, err
+	}
+	fmt.Fprintf(stdout, "deploy %s: %d uploaded, %d skipped, %d deleted, %d errors\n",
+		stats.DeployID, stats.Uploaded, stats.Skipped, stats.Deleted, stats.Errors)
+	return 0, nil
+}
+
+func runPlan(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error) {
+	plan, err := core.CreatePlan(cfg)
+	if err != nil {
+		return 1, err
+	}
+	code := 0
+	if plan.Unresolved() {
+		code = 1
+	}
+	if opts.json {
+		return code, printJSON(stdout, plan)
+	}
+
+	if plan.EmptyBucket {
+		fmt.Fprintf(stdout, "! no manifest found, %s would be emptied before uploading\n", plan.Bucket)
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, collision := range plan.Collisions {
+		resolution := "unresolved"
+		if collision.Winner != "" {
+			resolution = "keeping " + collision.Winner
+		}
+		fmt.Fprintf(w, "! %s\twritten by %s, %s\n", collision.Key, strings.Join(collision.Sources, ", "), resolution)
+	}
+	for _, change := range plan.BucketChanges {
+		fmt.Fprintf(w, "~ %s\t%s -> %s\n", change.Setting, change.From, change.To)
+	}
+	for _, upload := range plan.Uploads {
+		fmt.Fprintf(w, "+ %s\t%s\n", upload.Key, upload.Reason)
+	}
+	for _, key := range plan.Deletes {
+		fmt.Fprintf(w, "- %s\t\n", key)
+	}
+	w.Flush()
+	fmt.Fprintf(stdout, "\n%d to upload, %d to delete, %d unchanged\n", len(plan.Uploads), len(plan.Deletes), plan.Unchanged)
+	return code, nil
+}
+
+func runVerify(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error) {
+	report, err := core.Verify(cfg)
+	if err != nil {
+		return 1, err
+	}
+
+	if opts.json {
+		if err := printJSON(stdout, report); err != nil {
+			return 1, err
+		}
+	} else {
+		for _, key := range report.Unmanaged {
+			fmt.Fprintf(stdout, "unmanaged  %s\n", key)
+		}
+		for _, key := range report.Missing {
+			fmt.Fprintf(stdout, "missing    %s\n", key)
+		}
+		for _, m := range report.Mismatched {
+			fmt.Fprintf(stdout, "mismatch   %s %s: expected %q, got %q\n", m.Key, m.Field, m.Expected, m.Actual)
+		}
+	}
+
+	if report.HasDrift() {
+		return exitDrift, fmt.Errorf("drift detected: %d unmanaged, %d missing, %d mismatched",
+			len(report.Unmanaged), len(report.Missing), len(report.Mismatched))
+	}
+	if !opts.json {
+		fmt.Fprintln(stdout, "no drift detected")
+	}
+	return 0, nil
+}
+
+func runRollback(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error) {
+	if opts.list {
+		deploys, err := core.ListDeploys(cfg)
+		if err != nil {
+			return 1, err
+		}
+		w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tRECORDED")
+		for _, deploy := range deploys {
+			fmt.Fprintf(w, "%s\t%s\n", deploy.ID, deploy.Time.Local().Format(time.DateTime))
+		}
+		return 0, w.Flush()
+	}
+
+	id, err := core.Rollback(cfg, opts.to)
+	if err != nil {
+		return 1, err
+	}
+	fmt.Fprintf(stdout, "rolled back, recorded as deploy %s\n", id)
+	return 0, nil
+}
+
+func runManifestShow(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error) {
+	manifest, err := core.FetchManifest(cfg)
+	if err != nil {
+		return 1, err
+	}
+	if opts.json {
+		return 0, printJSON(stdout, manifest)
+	}
+
+	keys := make([]string, 0, len(manifest.M))
+	for key := range manifest.M {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tMD5\tCONTENT-TYPE\tCACHE-CONTROL")
+	for _, key := range keys {
+		v := manifest.M[key]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key, v.ContentMD5, v.ContentType, v.CacheControl)
+	}
+	return 0, w.Flush()
+}
+
+func runList(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error) {
+	prefix := ""
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+	objects, err := core.ListObjects(cfg, prefix)
+	if err != nil {
+		return 1, err
+	}
+	if opts.json {
+		return 0, printJSON(stdout, objects)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	for _, obj := range objects {
+		fmt.Fprintf(w, "%d\t  %s\t  %s\t\n", obj.Size, obj.LastModified.Local().Format(time.DateTime), obj.Key)
+	}
+	return 0, w.Flush()
+}
+
+func runPreviewDeploy(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error) {
+	preview, err := core.DeployPreview(cfg)
+	if err != nil {
+		return 1, err
+	}
+	if opts.json {
+		return 0, printJSON(stdout, preview)
+	}
+	location := preview.URL
+	if location == "" {
+		location = cfg.Bucket + "/" + preview.Prefix
+	}
+	fmt.Fprintf(stdout, "preview of #%d deployed to %s\n", preview.Number, location)
+	return 0, nil
+}
+
+func runPreviewCleanup(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error) {
+	preview, err := core.CleanupPreview(cfg)
+	if err != nil {
+		return 1, err
+	}
+	fmt.Fprintf(stdout, "removed preview of #%d from %s/%s\n", preview.Number, cfg.Bucket, preview.Prefix)
+	return 0, nil
+}
+
+func runPreviewGC(cfg config.Config, opts *options, args []string, stdout io.Writer) (int, error) {
+	removed, err := core.CollectPreviews(cfg, time.Now())
+	for _, prefix := range removed {
+		fmt.Fprintf(stdout, "removed %s\n", prefix)
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
@@ -3,13 +3,15 @@ package config
 import (
 	"os"
 	"path"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/rizaldntr/storage-service-website-action/logger"
 	"github.com/rizaldntr/storage-service-website-action/types"
 	"github.com/rizaldntr/storage-service-website-action/utils"
-	"github.com/sethvargo/go-githubactions"
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -33,45 +35,553 @@ type FileConfig struct {
 	ObjectRules                  []ObjectRule
 	RemoveHTMLExtension          bool
 	DuplicateHTMLWithNoExtension bool
+	// IndexDocument is the name of the directory index documents.
+	IndexDocument string
+	IndexMode     IndexMode
+	TrailingSlash TrailingSlash
+}
+
+// IndexMode selects the keys a nested index document, e.g. docs/index.html,
+// is uploaded to. The root index document is always kept as is.
+type IndexMode string
+
+const (
+	// KeepIndex uploads docs/index.html only, for website endpoints which
+	// serve it for docs/.
+	KeepIndex IndexMode = "keep"
+	// SlashIndex uploads it as docs/.
+	SlashIndex IndexMode = "slash"
+	// BareIndex uploads it as docs.
+	BareIndex IndexMode = "bare"
+	// BothIndex uploads it as docs/ and docs.
+	BothIndex IndexMode = "both"
+)
+
+// TrailingSlash adds redirect objects between the two URLs of a directory.
+type TrailingSlash string
+
+const (
+	NoTrailingSlashRedirect TrailingSlash = "none"
+	// AddTrailingSlash redirects docs to docs/.
+	AddTrailingSlash TrailingSlash = "add"
+	// RemoveTrailingSlash redirects docs/ to docs.
+	RemoveTrailingSlash TrailingSlash = "remove"
+)
+
+// Mount maps a local folder to a key prefix of the bucket. Its object rules
+// are matched before the global ones and its exclude patterns are added to
+// the global ones.
+type Mount struct {
+	Folder          string       `yaml:"folder"`
+	Prefix          string       `yaml:"prefix"`
+	ObjectRules     []ObjectRule `yaml:"object-rules"`
+	ExcludePatterns []string     `yaml:"exclude"`
+}
+
+// MultipartConfig controls when and how files are uploaded in parts.
+type MultipartConfig struct {
+	// Threshold is the file size from which multipart upload is used.
+	Threshold int64
+	// ChunkSize is the size of each part, the last part may be smaller.
+	ChunkSize int64
+	// Concurrency is the number of parts of a single file uploaded at once.
+	Concurrency int
+}
+
+// ConcurrencyConfig sets the number of workers of each pipeline stage.
+type ConcurrencyConfig struct {
+	Walk   int
+	Hash   int
+	Upload int
+	Delete int
+}
+
+// ThrottleConfig limits the load put on the network and the backend. Zero
+// disables the corresponding limit.
+type ThrottleConfig struct {
+	BytesPerSecond    int64
+	RequestsPerSecond float64
+}
+
+// SyncMode selects where the remote state used for skipping and deleting
+// objects comes from.
+type SyncMode string
+
+const (
+	// ManifestSync trusts the .incremental manifest stored in the bucket.
+	ManifestSync SyncMode = "manifest"
+	// RemoteSync lists the bucket and compares ETags and sizes instead.
+	RemoteSync SyncMode = "remote"
+)
+
+// ObjectOwnership mirrors the Object Ownership setting of the bucket.
+type ObjectOwnership string
+
+const (
+	ObjectWriter         ObjectOwnership = "object-writer"
+	BucketOwnerPreferred ObjectOwnership = "bucket-owner-preferred"
+	// BucketOwnerEnforced disables ACLs, requests carrying one fail with
+	// AccessControlListNotSupported.
+	BucketOwnerEnforced ObjectOwnership = "bucket-owner-enforced"
+)
+
+// AllowsACLs reports whether ACL headers may be sent to the bucket.
+func (o ObjectOwnership) AllowsACLs() bool {
+	return o != BucketOwnerEnforced
+}
+
+// CollisionPolicy decides what happens when several files are uploaded to
+// the same key.
+type CollisionPolicy string
+
+const (
+	// PreferFile keeps the file under its own name over pages without their
+	// extension, directory indexes and redirects of the same mount. Other
+	// collisions fail the deploy.
+	PreferFile CollisionPolicy = "prefer-file"
+	// FailOnCollision fails the deploy on any collision.
+	FailOnCollision CollisionPolicy = "error"
+	// FirstMount resolves collisions between mounts in favour of the mount
+	// listed first, and within a mount like PreferFile.
+	FirstMount CollisionPolicy = "first"
+	// LastMount resolves collisions between mounts in favour of the mount
+	// listed last, so later mounts overlay earlier ones.
+	LastMount CollisionPolicy = "last"
+)
+
+// Mode selects what the action does with the bucket.
+type Mode string
+
+const (
+	// DeployMode uploads the folder and removes leftover objects.
+	DeployMode Mode = "deploy"
+	// VerifyMode only reports drift between the bucket, the manifest and the
+	// folder without writing anything.
+	VerifyMode Mode = "verify"
+	// PreviewMode deploys the pull request under its own preview prefix.
+	PreviewMode Mode = "preview"
+	// CleanupPreviewMode deletes the preview of the pull request.
+	CleanupPreviewMode Mode = "cleanup-preview"
+	// GCPreviewsMode deletes every preview not updated within the max age.
+	GCPreviewsMode Mode = "gc-previews"
+)
+
+// PreviewConfig controls pull request preview deployments.
+type PreviewConfig struct {
+	// Prefix holds every preview, each one under pr-<number>/.
+	Prefix string
+	// Number is the pull request, read from the event payload when zero.
+	Number int
+	// BaseURL is where the bucket is served from, used for preview URLs.
+	BaseURL string
+	// MaxAge is the time after its last deploy a preview is removed by gc.
+	MaxAge time.Duration
+}
+
+// CDNProvider is the CDN invalidated after a deploy.
+type CDNProvider string
+
+const (
+	NoCDN      CDNProvider = "none"
+	CloudFront CDNProvider = "cloudfront"
+	Cloudflare CDNProvider = "cloudflare"
+	Fastly     CDNProvider = "fastly"
+)
+
+// CDNConfig controls the invalidation of changed paths after a deploy.
+type CDNConfig struct {
+	Provider CDNProvider
+	// ID is the CloudFront distribution, Cloudflare zone or Fastly service.
+	ID string
+	// Token authenticates with Cloudflare and Fastly, CloudFront uses the
+	// AWS credentials.
+	Token string
+	// BaseURL is where the CDN serves the site, Cloudflare and Fastly purge
+	// full URLs.
+	BaseURL string
+	// Wait blocks until the invalidation has completed.
+	Wait bool
+	// WildcardThreshold is the number of paths above which paths are
+	// collapsed to wildcards.
+	WildcardThreshold int
+	// Endpoint replaces the API endpoint of the provider, empty for the
+	// public one.
+	Endpoint string
+}
+
+// Enabled reports whether a CDN is invalidated after a deploy.
+func (c CDNConfig) Enabled() bool {
+	return c.Provider != NoCDN
+}
+
+// WebhookConfig controls the requests sent before and after a deploy.
+type WebhookConfig struct {
+	// URLs receive the result of every deploy.
+	URLs []string
+	// PreDeploy is asked before anything is written and may veto the deploy.
+	PreDeploy string
+	// Secret signs the payloads with HMAC-SHA256, empty to send them
+	// unsigned.
+	Secret string
+	// Retries is the number of times a failed request is repeated.
+	Retries int
+}
+
+// WebsiteConfig holds the bucket settings applied before a deploy. Each one
+// is only written when it differs from the bucket.
+type WebsiteConfig struct {
+	// Enabled applies the static website hosting configuration.
+	Enabled       bool
+	IndexDocument string
+	ErrorDocument string
+	RoutingRules  []types.RoutingRule
+	// PublicRead adds a statement allowing anyone to read objects to the
+	// bucket policy.
+	PublicRead bool
+	// CORSRules replace the CORS configuration of the bucket when set.
+	CORSRules []types.CORSRule
+}
+
+// defaultMinifyPatterns are the files minified unless patterns are given.
+var defaultMinifyPatterns = []string{"*.html", "*.htm", "*.css", "*.js", "*.mjs", "*.json", "*.svg"}
+
+// MinifyConfig removes comments and whitespace from HTML, CSS, JavaScript,
+// JSON and SVG files.
+type MinifyConfig struct {
+	// Enabled adds the minify transform first, unless Transforms already
+	// lists it.
+	Enabled bool
+	// Patterns select the files minified by their key, among those of a
+	// supported type.
+	Patterns []string
+}
+
+// defaultFingerprintPatterns are the assets fingerprinted unless patterns
+// are given.
+var defaultFingerprintPatterns = []string{
+	"*.css", "*.js", "*.mjs", "*.map",
+	"*.png", "*.jpg", "*.jpeg", "*.gif", "*.webp", "*.avif", "*.svg", "*.ico",
+	"*.woff", "*.woff2", "*.ttf", "*.otf", "*.eot",
+}
+
+// FingerprintConfig renames assets to name.<hash>.ext and rewrites the
+// references to them in HTML and CSS files.
+type FingerprintConfig struct {
+	// Enabled adds the fingerprint transform last, unless Transforms
+	// already lists it.
+	Enabled bool
+	// Patterns select the assets renamed by their key. HTML files are never
+	// renamed, as their URLs are the ones visitors know.
+	Patterns []string
+	// CacheControl is given to renamed assets, whose content never changes
+	// under a key.
+	CacheControl string
+}
+
+// SitemapConfig generates sitemap.xml from the HTML pages deployed.
+type SitemapConfig struct {
+	// Enabled adds the sitemap transform, unless Transforms already lists
+	// it.
+	Enabled bool
+	// BaseURL is the URL the deploy prefix is served at.
+	BaseURL string
+	Rules   []SitemapRule
+}
+
+// SitemapRule excludes the pages matching Pattern from the sitemap, or
+// sets their priority and change frequency. The first matching rule
+// applies.
+type SitemapRule struct {
+	Pattern    string   `yaml:"pattern"`
+	Exclude    bool     `yaml:"exclude"`
+	Priority   *float64 `yaml:"priority"`
+	ChangeFreq string   `yaml:"changefreq"`
+}
+
+// Robots selects the robots.txt generated.
+type Robots string
+
+const (
+	// NoRobots keeps robots.txt as it is in the folder, if any.
+	NoRobots Robots = "none"
+	// AllowRobots allows every crawler and points them at the sitemap.
+	AllowRobots Robots = "allow"
+	// DisallowRobots keeps crawlers out, e.g. of a staging environment.
+	DisallowRobots Robots = "disallow"
+)
+
+// SRIConfig adds integrity attributes to the scripts and stylesheets pages
+// load from the site.
+type SRIConfig struct {
+	// Enabled adds the sri transform after fingerprint, unless Transforms
+	// already lists it.
+	Enabled bool
+	// CSPHashes is the key of a JSON file listing the hashes of the inline
+	// scripts of every page, for a Content-Security-Policy. None when
+	// empty.
+	CSPHashes string
+}
+
+// ReportConfig names the report files written after a deploy, empty to
+// skip a report.
+type ReportConfig struct {
+	JSON  string
+	JUnit string
 }
 
 type Config struct {
 	Folder     string
 	FileConfig FileConfig
-	Bucket     string
-}
-
-func getACL() types.ObjectACL {
-	acl := os.Getenv("ACL")
-	if acl == "private" {
-		return types.PrivateACL
-	}
-	return types.PublicACL
+	// Mounts are the folders deployed, a single one for Folder unless
+	// mounts are configured.
+	Mounts []Mount
+	Bucket string
+	// Prefix is prepended to every key, empty or ending with a slash.
+	Prefix string
+	// Environment is the name of the environment selected from the
+	// configuration file, if any.
+	Environment string
+	SyncMode    SyncMode
+	Mode        Mode
+	// ObjectOwnership decides whether ACL headers are sent at all.
+	ObjectOwnership ObjectOwnership
+	Multipart       MultipartConfig
+	Concurrency     ConcurrencyConfig
+	Throttle        ThrottleConfig
+	Preview         PreviewConfig
+	// HashCache is the path of the on-disk digest cache, empty to disable it.
+	HashCache string
+	// ChecksumAlgorithm is the additional checksum sent with every upload,
+	// empty to only send Content-MD5.
+	ChecksumAlgorithm types.HashAlgorithm
+	Report            ReportConfig
+	CDN               CDNConfig
+	Webhooks          WebhookConfig
+	Website           WebsiteConfig
+	CollisionPolicy   CollisionPolicy
+	// Transforms are the names of the transforms applied to the files, in
+	// order, between walking and uploading.
+	Transforms  []string
+	Minify      MinifyConfig
+	Fingerprint FingerprintConfig
+	SRI         SRIConfig
+	Sitemap     SitemapConfig
+	Robots      Robots
 }
 
+// Get reads the configuration of the action once. Inputs take precedence
+// over the file named by the config input.
 func Get() Config {
 	once.Do(func() {
 		godotenv.Load(".env")
-		var rules []ObjectRule
-		if err := yaml.Unmarshal([]byte(os.Getenv("OBJECT_RULES")), &rules); err != nil {
-			githubactions.Fatalf("Failed to unmarshal file-configs: %v", err)
+		values := EnvValues()
+		if path := os.Getenv("CONFIG"); path != "" {
+			file, err := FileValues(path, values["ENVIRONMENT"])
+			if err != nil {
+				logger.Fatalf("Invalid configuration: %v", err)
+			}
+			values = file.Merge(values)
 		}
 
-		config = Config{
-			Folder: path.Clean(os.Getenv("FOLDER")) + "/",
-			FileConfig: FileConfig{
-				DefaultACL:                   getACL(),
-				DefaultCacheControl:          utils.GetEnvOrDefault("DEFAULT_CACHE_CONTROL", "max-age=2592000"),
-				DefaultHTMLCacheControl:      utils.GetEnvOrDefault("HTML_CACHE_CONTROL", "max-age=600"),
-				DefaultImageCacheControl:     utils.GetEnvOrDefault("IMAGE_CACHE_CONTROL", "max-age=864000"),
-				DefaultPDFCacheControl:       utils.GetEnvOrDefault("PDF_CACHE_CONTROL", "max-age=2592000"),
-				ExcludePatterns:              utils.GetActionInputAsSlice("EXCLUDE"),
-				ObjectRules:                  rules,
-				RemoveHTMLExtension:          utils.GetEnvOrDefault("REMOVE_HTML_EXTENSION", "false") == "true",
-				DuplicateHTMLWithNoExtension: utils.GetEnvOrDefault("DUPLICATE_HTML_WITH_NO_EXTENSION", "false") == "true",
-			},
-			Bucket: os.Getenv("BUCKET"),
+		var err error
+		config, err = Load(values)
+		if err != nil {
+			logger.Fatalf("Invalid configuration: %v", err)
+		}
+		if config.Bucket == "" {
+			logger.Fatalf("Invalid configuration: no bucket given, set the bucket input or the config file")
 		}
 	})
 	return config
 }
+
+// Load builds the configuration from raw values, applying defaults for
+// everything that is not set.
+func Load(values Values) (Config, error) {
+	config, p := parse(values)
+	if p.err == nil {
+		p.check(&config)
+	}
+	return config, p.err
+}
+
+// parse reads every setting of values on its own. Checks relating settings
+// to each other are left to check, as values may be partial, e.g. those of
+// a configuration file before the inputs are merged in.
+func parse(values Values) (Config, *parser) {
+	p := &parser{values: values}
+
+	config := Config{
+		Folder: path.Clean(values["FOLDER"]) + "/",
+		FileConfig: FileConfig{
+			DefaultACL:                   p.acl("ACL"),
+			DefaultCacheControl:          p.string("DEFAULT_CACHE_CONTROL", "max-age=2592000"),
+			DefaultHTMLCacheControl:      p.string("HTML_CACHE_CONTROL", "max-age=600"),
+			DefaultImageCacheControl:     p.string("IMAGE_CACHE_CONTROL", "max-age=864000"),
+			DefaultPDFCacheControl:       p.string("PDF_CACHE_CONTROL", "max-age=2592000"),
+			ExcludePatterns:              utils.GetActionInputAsSlice(values["EXCLUDE"]),
+			ObjectRules:                  p.objectRules("OBJECT_RULES"),
+			RemoveHTMLExtension:          p.bool("REMOVE_HTML_EXTENSION"),
+			DuplicateHTMLWithNoExtension: p.bool("DUPLICATE_HTML_WITH_NO_EXTENSION"),
+			IndexDocument:                p.string("INDEX_DOCUMENT", "index.html"),
+			IndexMode: IndexMode(p.oneOf("INDEX_MODE",
+				string(KeepIndex), string(SlashIndex), string(BareIndex), string(BothIndex))),
+			TrailingSlash: TrailingSlash(p.oneOf("TRAILING_SLASH",
+				string(NoTrailingSlashRedirect), string(AddTrailingSlash), string(RemoveTrailingSlash))),
+		},
+		Bucket:      values["BUCKET"],
+		Prefix:      p.prefix("PREFIX"),
+		Environment: values["ENVIRONMENT"],
+		SyncMode:    SyncMode(p.oneOf("SYNC_MODE", string(ManifestSync), string(RemoteSync))),
+		Mode: Mode(p.oneOf("MODE", string(DeployMode), string(VerifyMode),
+			string(PreviewMode), string(CleanupPreviewMode), string(GCPreviewsMode))),
+		ObjectOwnership: ObjectOwnership(p.oneOf("OBJECT_OWNERSHIP",
+			string(ObjectWriter), string(BucketOwnerPreferred), string(BucketOwnerEnforced))),
+		Multipart: MultipartConfig{
+			Threshold:   p.byteSize("MULTIPART_THRESHOLD", "64MiB"),
+			ChunkSize:   p.byteSize("MULTIPART_CHUNK_SIZE", "8MiB"),
+			Concurrency: p.int("MULTIPART_CONCURRENCY", 4),
+		},
+		Concurrency: ConcurrencyConfig{
+			Walk:   p.concurrency("WALK_CONCURRENCY", 20, 4),
+			Hash:   p.concurrency("HASH_CONCURRENCY", 8, 1),
+			Upload: p.concurrency("UPLOAD_CONCURRENCY", 30, 8),
+			Delete: p.concurrency("DELETE_CONCURRENCY", 10, 2),
+		},
+		Throttle: ThrottleConfig{
+			BytesPerSecond:    p.byteSize("BANDWIDTH_LIMIT", "0"),
+			RequestsPerSecond: p.float("REQUESTS_PER_SECOND"),
+		},
+		Preview: PreviewConfig{
+			Prefix:  p.keyPrefix("PREVIEW_PREFIX", p.string("PREVIEW_PREFIX", "previews")),
+			Number:  p.int("PR_NUMBER", 0),
+			BaseURL: values["PREVIEW_BASE_URL"],
+			MaxAge:  time.Duration(p.int("PREVIEW_MAX_AGE_DAYS", 14)) * 24 * time.Hour,
+		},
+		HashCache: values["HASH_CACHE"],
+
+		ChecksumAlgorithm: p.checksumAlgorithm("CHECKSUM_ALGORITHM"),
+		Report: ReportConfig{
+			JSON:  values["REPORT_JSON"],
+			JUnit: values["REPORT_JUNIT"],
+		},
+		CDN: CDNConfig{
+			Provider: CDNProvider(p.oneOf("CDN",
+				string(NoCDN), string(CloudFront), string(Cloudflare), string(Fastly))),
+			ID:                values["CDN_ID"],
+			Token:             values["CDN_TOKEN"],
+			BaseURL:           strings.TrimSuffix(values["CDN_BASE_URL"], "/"),
+			Wait:              p.bool("CDN_WAIT"),
+			WildcardThreshold: p.int("CDN_WILDCARD_THRESHOLD", 100),
+			Endpoint:          strings.TrimSuffix(values["CDN_ENDPOINT"], "/"),
+		},
+		Webhooks: WebhookConfig{
+			URLs:      p.urls("WEBHOOKS"),
+			PreDeploy: p.url("PRE_DEPLOY_HOOK", values["PRE_DEPLOY_HOOK"]),
+			Secret:    values["WEBHOOK_SECRET"],
+			Retries:   p.count("WEBHOOK_RETRIES", 3),
+		},
+		CollisionPolicy: CollisionPolicy(p.oneOf("COLLISION_POLICY",
+			string(PreferFile), string(FailOnCollision), string(FirstMount), string(LastMount))),
+		Transforms: utils.GetActionInputAsSlice(values["TRANSFORMS"]),
+		Minify: MinifyConfig{
+			Enabled:  p.bool("MINIFY"),
+			Patterns: utils.GetActionInputAsSlice(values["MINIFY_PATTERNS"]),
+		},
+		Fingerprint: FingerprintConfig{
+			Enabled:      p.bool("FINGERPRINT"),
+			Patterns:     utils.GetActionInputAsSlice(values["FINGERPRINT_PATTERNS"]),
+			CacheControl: p.string("FINGERPRINT_CACHE_CONTROL", "max-age=31536000, immutable"),
+		},
+		SRI: SRIConfig{
+			Enabled:   p.bool("SRI"),
+			CSPHashes: strings.TrimPrefix(values["SRI_CSP_HASHES"], "/"),
+		},
+		Sitemap: SitemapConfig{
+			Enabled: p.bool("SITEMAP"),
+			BaseURL: strings.TrimSuffix(p.url("SITEMAP_BASE_URL", values["SITEMAP_BASE_URL"]), "/"),
+			Rules:   p.sitemapRules("SITEMAP_RULES"),
+		},
+		Robots: Robots(p.oneOf("ROBOTS", string(NoRobots), string(AllowRobots), string(DisallowRobots))),
+		Website: WebsiteConfig{
+			Enabled:       p.bool("WEBSITE"),
+			IndexDocument: p.string("INDEX_DOCUMENT", "index.html"),
+			ErrorDocument: strings.TrimPrefix(values["ERROR_DOCUMENT"], "/"),
+			RoutingRules:  p.routingRules("ROUTING_RULES"),
+			PublicRead:    p.bool("PUBLIC_READ_POLICY"),
+			CORSRules:     p.corsRules("CORS_RULES"),
+		},
+	}
+	config.Mounts = p.mounts("MOUNTS", config.Folder)
+	if len(config.Minify.Patterns) == 0 {
+		config.Minify.Patterns = defaultMinifyPatterns
+	}
+	if len(config.Fingerprint.Patterns) == 0 {
+		config.Fingerprint.Patterns = defaultFingerprintPatterns
+	}
+	// minification runs first unless listed, so that later transforms see
+	// the final content, and fingerprinting last, hashing it
+	if config.Minify.Enabled && !slices.Contains(config.Transforms, "minify") {
+		config.Transforms = append([]string{"minify"}, config.Transforms...)
+	}
+	if config.Fingerprint.Enabled && !slices.Contains(config.Transforms, "fingerprint") {
+		config.Transforms = append(config.Transforms, "fingerprint")
+	}
+	// integrity covers the content as uploaded
+	if config.SRI.Enabled && !slices.Contains(config.Transforms, "sri") {
+		config.Transforms = append(config.Transforms, "sri")
+	}
+	if config.Sitemap.Enabled && !slices.Contains(config.Transforms, "sitemap") {
+		config.Transforms = append(config.Transforms, "sitemap")
+	}
+	if config.Robots != NoRobots && !slices.Contains(config.Transforms, "robots") {
+		config.Transforms = append(config.Transforms, "robots")
+	}
+	if p.err == nil && config.Multipart.ChunkSize < 5<<20 {
+		p.fail("MULTIPART_CHUNK_SIZE", "parts must be at least 5MiB")
+	}
+	if p.err == nil && strings.Contains(config.Website.IndexDocument, "/") {
+		p.fail("INDEX_DOCUMENT", "%q must be a file name without a slash", config.Website.IndexDocument)
+	}
+	return config, p
+}
+
+// check fails on settings that are required by others or cannot be used
+// together, and drops the ACLs a bucket without ACLs does not take.
+func (p *parser) check(config *Config) {
+	if p.err == nil && slices.Contains(config.Transforms, "sitemap") && config.Sitemap.BaseURL == "" {
+		p.fail("SITEMAP_BASE_URL", "required for the sitemap")
+	}
+	if p.err == nil && config.CDN.Enabled() {
+		p.cdn(config.CDN)
+	}
+	if p.err == nil {
+		p.trailingSlash(config.FileConfig)
+	}
+	if p.err == nil && !config.Website.Enabled {
+		for _, key := range []string{"ERROR_DOCUMENT", "ROUTING_RULES"} {
+			if p.values[key] != "" {
+				p.fail(key, "requires website to be enabled")
+			}
+		}
+	}
+	if p.err == nil && !config.ObjectOwnership.AllowsACLs() {
+		config.FileConfig.DefaultACL = p.enforcedACL("ACL", config.FileConfig.DefaultACL)
+		for i, rule := range config.FileConfig.ObjectRules {
+			config.FileConfig.ObjectRules[i].ACL = p.enforcedACL("OBJECT_RULES", rule.ACL)
+		}
+		for _, mount := range config.Mounts {
+			for i, rule := range mount.ObjectRules {
+				mount.ObjectRules[i].ACL = p.enforcedACL("MOUNTS", rule.ACL)
+			}
+		}
+	}
+}
+
+// MountFileConfig returns the file settings used for the files of mount.
+func (c Config) MountFileConfig(mount Mount) FileConfig {
+	fileConfig := c.FileConfig
+	fileConfig.ObjectRules = append(append([]ObjectRule{}, mount.ObjectRules...), c.FileConfig.ObjectRules...)
+	fileConfig.ExcludePatterns = append(append([]string{}, c.FileConfig.ExcludePatterns...), mount.ExcludePatterns...)
+	return fileConfig
+}
@@ -0,0 +1,361 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/IGLOU-EU/go-wildcard/v2"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+	"gopkg.in/yaml.v3"
+)
+
+// FileVersion is the version of the configuration file format understood
+// by this release. Files must declare it so the format can evolve.
+const FileVersion = 1
+
+// listKeys are the settings that accept a list of values in a file.
+var listKeys = map[string]bool{
+	"EXCLUDE":              true,
+	"OBJECT_RULES":         true,
+	"MOUNTS":               true,
+	"WEBHOOKS":             true,
+	"ROUTING_RULES":        true,
+	"CORS_RULES":           true,
+	"TRANSFORMS":           true,
+	"MINIFY_PATTERNS":      true,
+	"FINGERPRINT_PATTERNS": true,
+	"SITEMAP_RULES":        true,
+}
+
+// fileSettings are the settings of a file, or of one of its environments,
+// with the line each one was found on.
+type fileSettings struct {
+	values Values
+	lines  map[string]int
+}
+
+// fileEnvironment is a named set of settings overriding the base ones,
+// selected by name or when the Git ref matches one of refs.
+type fileEnvironment struct {
+	name string
+	refs []string
+	fileSettings
+}
+
+// FileValues reads a deploy configuration file, a YAML (or JSON) mapping
+// of action input names to values:
+//
+//	version: 1
+//	bucket: my-site
+//	exclude:
+//	  - "*.map"
+//	environments:
+//	  production:
+//	    ref: refs/heads/main
+//	    bucket: my-site-production
+//
+// The settings of the environment named by environment, or else of the
+// first one whose ref matches GITHUB_REF, override the base ones. Unknown
+// settings and invalid values are rejected with the line they were found
+// on.
+func FileValues(path, environment string) (Values, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%s: empty configuration file", path)
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fileError(path, root, "expected a mapping of settings")
+	}
+
+	base := fileSettings{values: make(Values), lines: make(map[string]int)}
+	var environments []fileEnvironment
+	version := 0
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name, value := root.Content[i], root.Content[i+1]
+		switch name.Value {
+		case "version":
+			if err := value.Decode(&version); err != nil || version != FileVersion {
+				return nil, fileError(path, value, "unsupported version %q, expected %d", value.Value, FileVersion)
+			}
+		case "environments":
+			if environments, err = readEnvironments(path, value); err != nil {
+				return nil, err
+			}
+		default:
+			if err := base.read(path, name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%s: missing version, add \"version: %d\"", path, FileVersion)
+	}
+
+	// Every environment is checked, not only the selected one, so a mistake
+	// in the production settings is already caught by a staging deploy.
+	if err := validate(path, base); err != nil {
+		return nil, err
+	}
+	for _, env := range environments {
+		if err := validate(path, base, env.fileSettings); err != nil {
+			return nil, err
+		}
+	}
+
+	if environment == "" {
+		environment = base.values["ENVIRONMENT"]
+	}
+	env, err := selectEnvironment(environments, environment, os.Getenv("GITHUB_REF"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if env == nil {
+		return base.values, nil
+	}
+	logger.Infof("Using environment %s from %s", env.name, path)
+	values := base.values.Merge(env.values)
+	values["ENVIRONMENT"] = env.name
+	return values, nil
+}
+
+// read adds the setting name with the given value.
+func (s fileSettings) read(path string, name, value *yaml.Node) error {
+	key := KeyOf(name.Value)
+	if !isKey(key) || name.Value != InputName(key) {
+		return fileError(path, name, "unknown setting %q", name.Value)
+	}
+	v, err := nodeValue(path, key, value)
+	if err != nil {
+		return err
+	}
+	s.values[key] = v
+	s.lines[key] = value.Line
+	return nil
+}
+
+func readEnvironments(path string, node *yaml.Node) ([]fileEnvironment, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fileError(path, node, "invalid environments: expected a mapping of environment names")
+	}
+
+	var environments []fileEnvironment
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, settings := node.Content[i], node.Content[i+1]
+		if settings.Kind != yaml.MappingNode {
+			return nil, fileError(path, settings, "invalid environment %s: expected a mapping of settings", name.Value)
+		}
+
+		env := fileEnvironment{
+			name:         name.Value,
+			fileSettings: fileSettings{values: make(Values), lines: make(map[string]int)},
+		}
+		for j := 0; j+1 < len(settings.Content); j += 2 {
+			key, value := settings.Content[j], settings.Content[j+1]
+			switch key.Value {
+			case "ref":
+				refs, err := nodeValue(path, "EXCLUDE", value)
+				if err != nil {
+					return nil, err
+				}
+				env.refs = strings.Split(refs, "\n")
+			case "version", "environments", InputName("ENVIRONMENT"):
+				return nil, fileError(path, key, "%s cannot be set in environment %s", key.Value, name.Value)
+			default:
+				if err := env.read(path, key, value); err != nil {
+					return nil, err
+				}
+			}
+		}
+		environments = append(environments, env)
+	}
+	return environments, nil
+}
+
+// validate parses the merged settings and points errors at the line of the
+// last file settings that set the invalid value. Settings required by
+// others may still be given as inputs, they are checked once merged.
+func validate(path string, settings ...fileSettings) error {
+	values := Values{}
+	for _, s := range settings {
+		values = values.Merge(s.values)
+	}
+	_, p := parse(values)
+	err := p.err
+	if err == nil {
+		return nil
+	}
+
+	var valueErr *ValueError
+	if errors.As(err, &valueErr) {
+		for i := len(settings) - 1; i >= 0; i-- {
+			if line := settings[i].lines[valueErr.Key]; line > 0 {
+				return fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+		}
+	}
+	return fmt.Errorf("%s: %v", path, err)
+}
+
+// selectEnvironment returns the environment called name, or the first one
+// with a ref pattern matching ref when no name is given. Patterns that do
+// not start with refs/ are matched against the branch or tag name.
+func selectEnvironment(environments []fileEnvironment, name, ref string) (*fileEnvironment, error) {
+	if name != "" {
+		for i := range environments {
+			if environments[i].name == name {
+				return &environments[i], nil
+			}
+		}
+		return nil, fmt.Errorf("unknown environment %q", name)
+	}
+
+	if ref == "" {
+		return nil, nil
+	}
+	short := strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/tags/")
+	for i := range environments {
+		for _, pattern := range environments[i].refs {
+			if wildcard.Match(pattern, ref) || (!strings.HasPrefix(pattern, "refs/") && wildcard.Match(pattern, short)) {
+				return &environments[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// nodeValue flattens a YAML value to the string form used by inputs. Lists
+// are joined with newlines and object-rules are kept as YAML.
+func nodeValue(path, key string, node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "", nil
+		}
+		return node.Value, nil
+	case yaml.SequenceNode:
+		if !listKeys[key] {
+			return "", fileError(path, node, "invalid %s: expected a single value, not a list", InputName(key))
+		}
+		switch key {
+		case "OBJECT_RULES":
+			return objectRulesValue(path, node)
+		case "MOUNTS":
+			return mountsValue(path, node)
+		case "ROUTING_RULES", "CORS_RULES", "SITEMAP_RULES":
+			// checked when the settings are loaded
+			out, err := yaml.Marshal(node)
+			return string(out), err
+		}
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fileError(path, item, "invalid %s: expected a string", InputName(key))
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, "\n"), nil
+	default:
+		return "", fileError(path, node, "invalid %s: expected a value, not a mapping", InputName(key))
+	}
+}
+
+// objectRulesValue checks every rule where it is written, so errors carry
+// the right line, and returns the rules as YAML.
+func objectRulesValue(path string, node *yaml.Node) (string, error) {
+	for _, rule := range node.Content {
+		if rule.Kind != yaml.MappingNode {
+			return "", fileError(path, rule, "invalid object-rules: expected a rule with a pattern")
+		}
+		hasPattern := false
+		for i := 0; i+1 < len(rule.Content); i += 2 {
+			field, value := rule.Content[i], rule.Content[i+1]
+			if value.Kind != yaml.ScalarNode {
+				return "", fileError(path, value, "invalid object-rules: expected a string for %s", field.Value)
+			}
+			switch field.Value {
+			case "pattern":
+				hasPattern = value.Value != ""
+			case "acl":
+				if _, err := types.ParseObjectACL(value.Value); err != nil {
+					return "", fileError(path, value, "invalid object-rules: %v", err)
+				}
+			case "cache-control":
+			default:
+				return "", fileError(path, field, "invalid object-rules: unknown field %q", field.Value)
+			}
+		}
+		if !hasPattern {
+			return "", fileError(path, rule, "invalid object-rules: rule has no pattern")
+		}
+	}
+
+	var rules []ObjectRule
+	if err := node.Decode(&rules); err != nil {
+		return "", fileError(path, node, "invalid object-rules: %v", err)
+	}
+	out, err := yaml.Marshal(rules)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// mountsValue checks every mount where it is written and returns the
+// mounts as YAML.
+func mountsValue(path string, node *yaml.Node) (string, error) {
+	for _, mount := range node.Content {
+		if mount.Kind != yaml.MappingNode {
+			return "", fileError(path, mount, "invalid mounts: expected a mount with a folder")
+		}
+		hasFolder := false
+		for i := 0; i+1 < len(mount.Content); i += 2 {
+			field, value := mount.Content[i], mount.Content[i+1]
+			var err error
+			switch field.Value {
+			case "folder":
+				hasFolder = value.Value != ""
+			case "prefix":
+			case "exclude":
+				if value.Kind != yaml.SequenceNode {
+					return "", fileError(path, value, "invalid mounts: expected a list of exclude patterns")
+				}
+				_, err = nodeValue(path, "EXCLUDE", value)
+			case "object-rules":
+				if value.Kind != yaml.SequenceNode {
+					return "", fileError(path, value, "invalid mounts: expected a list of object rules")
+				}
+				_, err = objectRulesValue(path, value)
+			default:
+				return "", fileError(path, field, "invalid mounts: unknown field %q", field.Value)
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		if !hasFolder {
+			return "", fileError(path, mount, "invalid mounts: mount has no folder")
+		}
+	}
+
+	out, err := yaml.Marshal(node)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func fileError(path string, node *yaml.Node, format string, args ...any) error {
+	return fmt.Errorf("%s:%d: %s", path, node.Line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "deploy.yaml")
+	if err := os.WriteFile(path, []byte(strings.TrimLeft(content, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileValuesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "missing version",
+			content: "bucket: site\n",
+			want:    `missing version, add "version: 1"`,
+		},
+		{
+			name:    "unsupported version",
+			content: "version: 2\nbucket: site\n",
+			want:    `deploy.yaml:1: unsupported version "2", expected 1`,
+		},
+		{
+			name:    "unknown setting",
+			content: "version: 1\nbucket: site\nbuckets: other\n",
+			want:    `deploy.yaml:3: unknown setting "buckets"`,
+		},
+		{
+			name:    "input spelled as key",
+			content: "version: 1\nBUCKET: site\n",
+			want:    `deploy.yaml:2: unknown setting "BUCKET"`,
+		},
+		{
+			name:    "invalid value",
+			content: "version: 1\nbucket: site\nsync-mode: mirror\n",
+			want:    `deploy.yaml:3: invalid sync-mode: "mirror"`,
+		},
+		{
+			name:    "list for a single value",
+			content: "version: 1\nbucket:\n  - a\n  - b\n",
+			want:    "deploy.yaml:3: invalid bucket: expected a single value, not a list",
+		},
+		{
+			name:    "invalid object rule",
+			content: "version: 1\nobject-rules:\n  - pattern: \"*\"\n    acl: pubic\n",
+			want:    `deploy.yaml:4: invalid object-rules: unknown acl "pubic"`,
+		},
+		{
+			name: "invalid environment value",
+			content: `
+version: 1
+bucket: site
+environments:
+  staging:
+    acl: private
+  production:
+    acl: pubic
+`,
+			want: `deploy.yaml:7: invalid acl`,
+		},
+		{
+			name: "version in environment",
+			content: `
+version: 1
+environments:
+  staging:
+    version: 1
+`,
+			want: "deploy.yaml:4: version cannot be set in environment staging",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FileValues(writeConfigFile(t, tt.content), "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("FileValues() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileValuesEnvironments(t *testing.T) {
+	path := writeConfigFile(t, `
+version: 1
+bucket: site-staging
+html-cache-control: max-age=600
+environments:
+  staging:
+    ref: [develop, "release/*"]
+  production:
+    ref: [main, "refs/tags/v*"]
+    bucket: site
+`)
+	tests := []struct {
+		name, environment, ref string
+		wantBucket, wantEnv    string
+	}{
+		{"no ref", "", "", "site-staging", ""},
+		{"branch", "", "refs/heads/main", "site", "production"},
+		{"branch pattern", "", "refs/heads/release/1.2", "site-staging", "staging"},
+		{"tag", "", "refs/tags/v1.0.0", "site", "production"},
+		{"no match", "", "refs/heads/feature", "site-staging", ""},
+		{"full ref pattern only matches full refs", "", "refs/heads/v1", "site-staging", ""},
+		{"name wins over ref", "staging", "refs/heads/main", "site-staging", "staging"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_REF", tt.ref)
+			values, err := FileValues(path, tt.environment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if values["BUCKET"] != tt.wantBucket || values["ENVIRONMENT"] != tt.wantEnv {
+				t.Errorf("bucket, environment = %q, %q, want %q, %q", values["BUCKET"], values["ENVIRONMENT"], tt.wantBucket, tt.wantEnv)
+			}
+			if values["HTML_CACHE_CONTROL"] != "max-age=600" {
+				t.Errorf("base html-cache-control = %q, want it kept", values["HTML_CACHE_CONTROL"])
+			}
+		})
+	}
+
+	t.Run("unknown name", func(t *testing.T) {
+		if _, err := FileValues(path, "qa"); err == nil || !strings.Contains(err.Error(), `unknown environment "qa"`) {
+			t.Errorf("FileValues() error = %v, want unknown environment", err)
+		}
+	})
+}
+
+func TestFileValuesPrecedence(t *testing.T) {
+	t.Setenv("GITHUB_REF", "")
+	path := writeConfigFile(t, `
+version: 1
+bucket: site
+prefix: docs
+exclude:
+  - "*.map"
+  - "*.ts"
+`)
+	file, err := FileValues(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(file.Merge(Values{"PREFIX": "preview", "BUCKET": ""}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Prefix != "preview/" {
+		t.Errorf("prefix = %q, want the input's", cfg.Prefix)
+	}
+	if cfg.Bucket != "site" {
+		t.Errorf("bucket = %q, want the file's as the input is empty", cfg.Bucket)
+	}
+	if got := strings.Join(cfg.FileConfig.ExcludePatterns, ","); got != "*.map,*.ts" {
+		t.Errorf("exclude = %q, want the file's list", got)
+	}
+}
+
+// Settings required by others may be given as inputs, e.g. secrets, so the
+// file is valid on its own and only the merged values are checked.
+func TestFileValuesRequiredByInputs(t *testing.T) {
+	t.Setenv("GITHUB_REF", "")
+	tests := []struct {
+		name    string
+		content string
+		inputs  Values
+		missing string
+	}{
+		{
+			name:    "cdn token",
+			content: "version: 1\nbucket: site\ncdn: cloudflare\ncdn-id: zone\ncdn-base-url: https://example.com\n",
+			inputs:  Values{"CDN_TOKEN": "secret"},
+			missing: "invalid cdn-token: required for cloudflare",
+		},
+		{
+			name:    "sitemap base URL",
+			content: "version: 1\nbucket: site\nsitemap: true\n",
+			inputs:  Values{"SITEMAP_BASE_URL": "https://example.com"},
+			missing: "invalid sitemap-base-url: required for the sitemap",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := FileValues(writeConfigFile(t, tt.content), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(file.Merge(tt.inputs)); err != nil {
+				t.Errorf("Load() of the merged values: %v", err)
+			}
+			if _, err := Load(file); err == nil || err.Error() != tt.missing {
+				t.Errorf("Load() of the file alone error = %v, want %q", err, tt.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rizaldntr/storage-service-website-action/types"
+	"github.com/rizaldntr/storage-service-website-action/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// ValueError reports an invalid setting.
+type ValueError struct {
+	Key     string
+	Message string
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", InputName(e.Key), e.Message)
+}
+
+// parser reads typed settings from Values and keeps the first error, so a
+// whole configuration can be read before checking for failures.
+type parser struct {
+	values Values
+	err    error
+}
+
+func (p *parser) fail(key, format string, args ...any) {
+	if p.err == nil {
+		p.err = &ValueError{Key: key, Message: fmt.Sprintf(format, args...)}
+	}
+}
+
+func (p *parser) string(key, defaultValue string) string {
+	if value := p.values[key]; value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func (p *parser) bool(key string) bool {
+	value := p.values[key]
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.fail(key, "%q is not a boolean", value)
+	}
+	return b
+}
+
+func (p *parser) int(key string, defaultValue int) int {
+	value := p.values[key]
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		p.fail(key, "%q is not a positive number", value)
+	}
+	return n
+}
+
+// count reads a number that may be 0, e.g. of retries.
+func (p *parser) count(key string, defaultValue int) int {
+	value := p.values[key]
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		p.fail(key, "%q is not a number of 0 or more", value)
+	}
+	return n
+}
+
+func (p *parser) float(key string) float64 {
+	value := p.values[key]
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		p.fail(key, "%q is not a positive number", value)
+	}
+	return f
+}
+
+func (p *parser) byteSize(key, defaultValue string) int64 {
+	size, err := utils.ParseByteSize(p.string(key, defaultValue))
+	if err != nil {
+		p.fail(key, "%v", err)
+	}
+	return size
+}
+
+// concurrency reads a worker count which may also be "auto" to scale with
+// the number of CPUs.
+func (p *parser) concurrency(key string, defaultValue, perCPU int) int {
+	if p.values[key] == "auto" {
+		return perCPU * runtime.NumCPU()
+	}
+	return p.int(key, defaultValue)
+}
+
+// oneOf reads a value restricted to allowed, the first one being the
+// default.
+func (p *parser) oneOf(key string, allowed ...string) string {
+	value := p.string(key, allowed[0])
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	p.fail(key, "%q, expected one of %s", value, strings.Join(allowed, ", "))
+	return allowed[0]
+}
+
+func (p *parser) acl(key string) types.ObjectACL {
+	acl, err := types.ParseObjectACL(p.string(key, string(types.PublicReadACL)))
+	if err != nil {
+		p.fail(key, "%v", err)
+	}
+	return acl
+}
+
+// enforcedACL checks an ACL of a bucket with ACLs disabled. The default is
+// dropped silently, an explicitly set ACL other than none is an error.
+func (p *parser) enforcedACL(key string, acl types.ObjectACL) types.ObjectACL {
+	if acl == "" || acl == types.NoACL || (key == "ACL" && p.values[key] == "") {
+		return types.NoACL
+	}
+	p.fail(key, "%s cannot be used when object-ownership is %s, use none", acl, BucketOwnerEnforced)
+	return types.NoACL
+}
+
+// objectRules reads a YAML list of rules, rejecting unknown fields.
+func (p *parser) objectRules(key string) []ObjectRule {
+	var rules []ObjectRule
+	if !p.yaml(key, &rules) {
+		return nil
+	}
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			p.fail(key, "rule %d has no pattern", i+1)
+		}
+	}
+	return rules
+}
+
+// mounts reads a YAML list of mounts, or returns a single mount of folder
+// when none is configured.
+func (p *parser) mounts(key, folder string) []Mount {
+	value := p.values[key]
+	if value == "" {
+		return []Mount{{Folder: folder}}
+	}
+	if p.values["FOLDER"] != "" {
+		p.fail(key, "cannot be used together with folder")
+		return nil
+	}
+
+	var mounts []Mount
+	if !p.yaml(key, &mounts) {
+		return nil
+	}
+	for i := range mounts {
+		if mounts[i].Folder == "" {
+			p.fail(key, "mount %d has no folder", i+1)
+		}
+		mounts[i].Folder = path.Clean(mounts[i].Folder) + "/"
+		mounts[i].Prefix = p.keyPrefix(key, mounts[i].Prefix)
+		for j, rule := range mounts[i].ObjectRules {
+			if rule.Pattern == "" {
+				p.fail(key, "rule %d of mount %d has no pattern", j+1, i+1)
+			}
+		}
+	}
+	return mounts
+}
+
+// prefix reads a key prefix, without a leading slash and with a trailing one.
+func (p *parser) prefix(key string) string {
+	return p.keyPrefix(key, p.values[key])
+}
+
+func (p *parser) keyPrefix(key, value string) string {
+	prefix := strings.Trim(value, "/")
+	if prefix == "" {
+		return ""
+	}
+	if strings.Contains(prefix, "//") || strings.Contains("/"+prefix+"/", "/../") {
+		p.fail(key, "%q is not a valid key prefix", value)
+	}
+	return prefix + "/"
+}
+
+// checksumAlgorithm reads an algorithm in any case, S3 spells them SHA256
+// and CRC32C.
+func (p *parser) checksumAlgorithm(key string) types.HashAlgorithm {
+	allowed := []string{"none", string(types.SHA256), string(types.CRC32C)}
+	algorithm := strings.ToLower(p.string(key, allowed[0]))
+	if !slices.Contains(allowed, algorithm) {
+		p.fail(key, "%q, expected one of %s", p.values[key], strings.Join(allowed, ", "))
+		return ""
+	}
+	if algorithm == "none" {
+		return ""
+	}
+	return types.HashAlgorithm(algorithm)
+}
+
+// cdn checks that the settings required by the CDN provider are given.
+func (p *parser) cdn(cdn CDNConfig) {
+	switch {
+	case cdn.ID == "":
+		p.fail("CDN_ID", "required for %s", cdn.Provider)
+	case cdn.Provider != CloudFront && cdn.Token == "":
+		p.fail("CDN_TOKEN", "required for %s", cdn.Provider)
+	case cdn.Provider != CloudFront && cdn.BaseURL == "":
+		p.fail("CDN_BASE_URL", "required for %s", cdn.Provider)
+	default:
+		p.url("CDN_BASE_URL", cdn.BaseURL)
+	}
+}
+
+// url checks that value, if set, is an absolute http or https URL.
+func (p *parser) url(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	if u, err := url.Parse(value); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		p.fail(key, "%q is not an http or https URL", value)
+	}
+	return value
+}
+
+// urls reads a list of URLs, one per line.
+func (p *parser) urls(key string) []string {
+	urls := utils.GetActionInputAsSlice(p.values[key])
+	for _, u := range urls {
+		p.url(key, u)
+	}
+	return urls
+}
+
+// routingRules reads a YAML list of website routing rules.
+func (p *parser) routingRules(key string) []types.RoutingRule {
+	var rules []types.RoutingRule
+	if !p.yaml(key, &rules) {
+		return nil
+	}
+	for i, rule := range rules {
+		redirect := rule.Redirect
+		switch {
+		case redirect == types.RoutingRedirect{}:
+			p.fail(key, "rule %d has no redirect", i+1)
+		case redirect.ReplaceKeyPrefixWith != "" && redirect.ReplaceKeyWith != "":
+			p.fail(key, "rule %d sets both replace-key-prefix-with and replace-key-with", i+1)
+		case redirect.Protocol != "" && redirect.Protocol != "http" && redirect.Protocol != "https":
+			p.fail(key, "rule %d has protocol %q, expected http or https", i+1, redirect.Protocol)
+		}
+	}
+	return rules
+}
+
+// corsRules reads a YAML list of CORS rules.
+func (p *parser) corsRules(key string) []types.CORSRule {
+	var rules []types.CORSRule
+	if !p.yaml(key, &rules) {
+		return nil
+	}
+	for i, rule := range rules {
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 {
+			p.fail(key, "rule %d needs allowed-origins and allowed-methods", i+1)
+		}
+		for _, method := range rule.AllowedMethods {
+			switch method {
+			case "GET", "PUT", "HEAD", "POST", "DELETE":
+			default:
+				p.fail(key, "rule %d has method %q, expected GET, PUT, HEAD, POST or DELETE", i+1, method)
+			}
+		}
+	}
+	return rules
+}
+
+// sitemapRules reads a YAML list of sitemap rules.
+func (p *parser) sitemapRules(key string) []SitemapRule {
+	var rules []SitemapRule
+	if !p.yaml(key, &rules) {
+		return nil
+	}
+	for i, rule := range rules {
+		switch rule.ChangeFreq {
+		case "", "always", "hourly", "daily", "weekly", "monthly", "yearly", "never":
+		default:
+			p.fail(key, "rule %d has changefreq %q, expected always, hourly, daily, weekly, monthly, yearly or never", i+1, rule.ChangeFreq)
+		}
+		switch {
+		case rule.Pattern == "":
+			p.fail(key, "rule %d has no pattern", i+1)
+		case rule.Priority != nil && (*rule.Priority < 0 || *rule.Priority > 1):
+			p.fail(key, "rule %d has priority %v, expected a value from 0 to 1", i+1, *rule.Priority)
+		}
+	}
+	return rules
+}
+
+// yaml decodes the YAML value of key into out, rejecting unknown fields.
+// It reports whether a value was decoded.
+func (p *parser) yaml(key string, out any) bool {
+	value := p.values[key]
+	if value == "" {
+		return false
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(value))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil {
+		p.fail(key, "%v", strings.TrimPrefix(err.Error(), "yaml: "))
+		return false
+	}
+	return true
+}
+
+// trailingSlash checks that the redirect points at a URL the index mode
+// uploads the index documents to.
+func (p *parser) trailingSlash(config FileConfig) {
+	switch {
+	case config.TrailingSlash == AddTrailingSlash && (config.IndexMode == BareIndex || config.IndexMode == BothIndex):
+		p.fail("TRAILING_SLASH", "add cannot be used with index-mode %s, which uploads the URL without a slash", config.IndexMode)
+	case config.TrailingSlash == RemoveTrailingSlash && config.IndexMode != BareIndex:
+		p.fail("TRAILING_SLASH", "remove requires index-mode %s", BareIndex)
+	}
+}
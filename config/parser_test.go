@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+func TestChecksumAlgorithm(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    types.HashAlgorithm
+		wantErr bool
+	}{
+		{"", "", false},
+		{"none", "", false},
+		{"sha256", types.SHA256, false},
+		{"SHA256", types.SHA256, false},
+		{"CRC32C", types.CRC32C, false},
+		{"md5", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			p := parser{values: Values{"CHECKSUM_ALGORITHM": tt.value}}
+			got := p.checksumAlgorithm("CHECKSUM_ALGORITHM")
+			if (p.err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", p.err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("checksumAlgorithm(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"", 3, false},
+		{"0", 0, false},
+		{"5", 5, false},
+		{"-1", 0, true},
+		{"many", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			cfg, err := Load(Values{"WEBHOOK_RETRIES": tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.Webhooks.Retries != tt.want {
+				t.Errorf("retries = %d, want %d", cfg.Webhooks.Retries, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Values holds raw settings keyed by the environment variable the action
+// passes them in, e.g. "BUCKET" for the bucket input. Empty values are
+// treated as unset.
+type Values map[string]string
+
+// Keys lists every supported setting.
+var Keys = []string{
+	"MODE",
+	"ENVIRONMENT",
+	"BUCKET",
+	"PREFIX",
+	"FOLDER",
+	"MOUNTS",
+	"EXCLUDE",
+	"SYNC_MODE",
+	"ACL",
+	"OBJECT_OWNERSHIP",
+	"OBJECT_RULES",
+	"DEFAULT_CACHE_CONTROL",
+	"HTML_CACHE_CONTROL",
+	"IMAGE_CACHE_CONTROL",
+	"PDF_CACHE_CONTROL",
+	"REMOVE_HTML_EXTENSION",
+	"DUPLICATE_HTML_WITH_NO_EXTENSION",
+	"MULTIPART_THRESHOLD",
+	"MULTIPART_CHUNK_SIZE",
+	"MULTIPART_CONCURRENCY",
+	"WALK_CONCURRENCY",
+	"HASH_CONCURRENCY",
+	"UPLOAD_CONCURRENCY",
+	"DELETE_CONCURRENCY",
+	"BANDWIDTH_LIMIT",
+	"REQUESTS_PER_SECOND",
+	"PREVIEW_PREFIX",
+	"PR_NUMBER",
+	"PREVIEW_BASE_URL",
+	"PREVIEW_MAX_AGE_DAYS",
+	"HASH_CACHE",
+	"CHECKSUM_ALGORITHM",
+	"REPORT_JSON",
+	"REPORT_JUNIT",
+	"CDN",
+	"CDN_ID",
+	"CDN_TOKEN",
+	"CDN_BASE_URL",
+	"CDN_WAIT",
+	"CDN_WILDCARD_THRESHOLD",
+	"CDN_ENDPOINT",
+	"WEBHOOKS",
+	"WEBHOOK_SECRET",
+	"WEBHOOK_RETRIES",
+	"PRE_DEPLOY_HOOK",
+	"WEBSITE",
+	"INDEX_DOCUMENT",
+	"ERROR_DOCUMENT",
+	"ROUTING_RULES",
+	"PUBLIC_READ_POLICY",
+	"CORS_RULES",
+	"INDEX_MODE",
+	"TRAILING_SLASH",
+	"COLLISION_POLICY",
+	"TRANSFORMS",
+	"MINIFY",
+	"MINIFY_PATTERNS",
+	"FINGERPRINT",
+	"FINGERPRINT_PATTERNS",
+	"FINGERPRINT_CACHE_CONTROL",
+	"SRI",
+	"SRI_CSP_HASHES",
+	"SITEMAP",
+	"SITEMAP_BASE_URL",
+	"SITEMAP_RULES",
+	"ROBOTS",
+}
+
+// InputName converts a key to the name of its action input, BUCKET to
+// bucket and SYNC_MODE to sync-mode.
+func InputName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// KeyOf converts an action input name back to its key.
+func KeyOf(input string) string {
+	return strings.ReplaceAll(strings.ToUpper(input), "-", "_")
+}
+
+func isKey(key string) bool {
+	for _, k := range Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// EnvValues reads every key from the environment.
+func EnvValues() Values {
+	values := make(Values)
+	for _, key := range Keys {
+		if value := os.Getenv(key); value != "" {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// Merge returns a copy of v overridden by every non-empty value of others,
+// later ones taking precedence.
+func (v Values) Merge(others ...Values) Values {
+	merged := make(Values, len(v))
+	for key, value := range v {
+		merged[key] = value
+	}
+	for _, other := range others {
+		for key, value := range other {
+			if value != "" {
+				merged[key] = value
+			}
+		}
+	}
+	return merged
+}
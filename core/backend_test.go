@@ -0,0 +1,171 @@
+package core
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// memoryObject is an object of a memoryBackend.
+type memoryObject struct {
+	body         []byte
+	contentType  string
+	cacheControl string
+	acl          types.ObjectACL
+	redirect     string
+	lastModified time.Time
+}
+
+// memoryBackend is a Backend keeping the objects of a bucket in memory.
+type memoryBackend struct {
+	sync.Mutex
+	objects  map[string]memoryObject
+	versions []types.ObjectVersion
+	// bodies are the objects of versions, by version ID
+	bodies map[string]memoryObject
+	copies []types.CopyObjectRequest
+}
+
+func newMemoryBackend(keys ...string) *memoryBackend {
+	b := &memoryBackend{objects: map[string]memoryObject{}, bodies: map[string]memoryObject{}}
+	for _, key := range keys {
+		b.objects[key] = memoryObject{body: []byte(key)}
+	}
+	return b
+}
+
+// keys returns the keys of the objects, sorted.
+func (b *memoryBackend) keys() []string {
+	b.Lock()
+	defer b.Unlock()
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (b *memoryBackend) GetObject(key string) ([]byte, error) {
+	b.Lock()
+	defer b.Unlock()
+	obj, ok := b.objects[key]
+	if !ok {
+		return nil, types.ObjectNotFoundError
+	}
+	return obj.body, nil
+}
+
+func (b *memoryBackend) HeadObject(key string) (types.ObjectInfo, error) {
+	b.Lock()
+	defer b.Unlock()
+	obj, ok := b.objects[key]
+	if !ok {
+		return types.ObjectInfo{}, types.ObjectNotFoundError
+	}
+	info := objectInfo(key, obj)
+	info.ContentType = obj.contentType
+	info.CacheControl = obj.cacheControl
+	return info, nil
+}
+
+func (b *memoryBackend) ListObjects(prefix string) ([]types.ObjectInfo, error) {
+	b.Lock()
+	defer b.Unlock()
+	var objects []types.ObjectInfo
+	for key, obj := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, objectInfo(key, obj))
+		}
+	}
+	return objects, nil
+}
+
+func (b *memoryBackend) ListObjectVersions(prefix string) ([]types.ObjectVersion, error) {
+	var versions []types.ObjectVersion
+	for _, version := range b.versions {
+		if strings.HasPrefix(version.Key, prefix) {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (b *memoryBackend) CopyObject(request types.CopyObjectRequest) error {
+	b.Lock()
+	defer b.Unlock()
+	obj := b.bodies[request.VersionID]
+	// like S3, a copy does not keep the website redirect
+	obj.redirect = ""
+	obj.acl = request.ACL
+	b.objects[request.Key] = obj
+	b.copies = append(b.copies, request)
+	return nil
+}
+
+func (b *memoryBackend) PutObject(request types.PutObjectRequest) error {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.objects[request.Key] = memoryObject{
+		body:         body,
+		contentType:  request.ContentType,
+		cacheControl: request.CacheControl,
+		acl:          request.ACL,
+		redirect:     request.WebsiteRedirectLocation,
+	}
+	return nil
+}
+
+func (b *memoryBackend) CreateMultipartUpload(request types.PutObjectRequest) (string, error) {
+	panic("multipart uploads are not supported")
+}
+
+func (b *memoryBackend) UploadPart(request types.UploadPartRequest) (types.CompletedPart, error) {
+	panic("multipart uploads are not supported")
+}
+
+func (b *memoryBackend) CompleteMultipartUpload(key, uploadID string, algorithm types.HashAlgorithm, parts []types.CompletedPart) error {
+	panic("multipart uploads are not supported")
+}
+
+func (b *memoryBackend) AbortMultipartUpload(key, uploadID string) error {
+	return nil
+}
+
+func (b *memoryBackend) DeleteObject(key string) error {
+	b.Lock()
+	defer b.Unlock()
+	maps.DeleteFunc(b.objects, func(k string, _ memoryObject) bool { return k == key })
+	return nil
+}
+
+func (b *memoryBackend) DeleteObjects(keys []string) error {
+	b.Lock()
+	defer b.Unlock()
+	maps.DeleteFunc(b.objects, func(key string, _ memoryObject) bool { return slices.Contains(keys, key) })
+	return nil
+}
+
+func (b *memoryBackend) EmptyBucket() error {
+	b.Lock()
+	defer b.Unlock()
+	b.objects = map[string]memoryObject{}
+	return nil
+}
+
+func objectInfo(key string, obj memoryObject) types.ObjectInfo {
+	sum := md5.Sum(obj.body)
+	return types.ObjectInfo{Key: key, Size: int64(len(obj.body)), ETag: hex.EncodeToString(sum[:]), LastModified: obj.lastModified}
+}
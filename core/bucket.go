@@ -0,0 +1,264 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/rizaldntr/storage-service-website-action/backend"
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// publicReadSid and privateInternalSid identify the statements managed in
+// the bucket policy, other statements are left untouched.
+const (
+	publicReadSid      = "PublicReadGetObject"
+	privateInternalSid = "DenyInternalGetObject"
+)
+
+// BucketConfigurator reads and writes settings of the bucket itself rather
+// than of its objects. They apply to the whole bucket, whatever the prefix.
+type BucketConfigurator interface {
+	GetBucketWebsite() (*types.WebsiteConfig, error)
+	PutBucketWebsite(website types.WebsiteConfig) error
+	// AccountID is the account kept able to read the internal keys once
+	// the bucket is public.
+	AccountID() (string, error)
+	GetBucketPolicy() (string, error)
+	PutBucketPolicy(policy string) error
+	GetBucketCORS() ([]types.CORSRule, error)
+	PutBucketCORS(rules []types.CORSRule) error
+}
+
+// BucketChange is a bucket setting that differs from the configuration.
+type BucketChange struct {
+	Setting string `json:"setting"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+
+	apply func() error
+}
+
+func newBucketConfigurator(config config.Config) (BucketConfigurator, error) {
+	return backend.NewS3(config)
+}
+
+func configuresBucket(website config.WebsiteConfig) bool {
+	return website.Enabled || website.PublicRead || len(website.CORSRules) > 0
+}
+
+// PlanBucket returns the bucket settings a deploy would change.
+func PlanBucket(config config.Config) ([]BucketChange, error) {
+	if !configuresBucket(config.Website) {
+		return nil, nil
+	}
+	bucket, err := newBucketConfigurator(config)
+	if err != nil {
+		return nil, err
+	}
+	return diffBucket(bucket, config)
+}
+
+// configureBucket applies the bucket settings that differ from config.
+func configureBucket(bucket BucketConfigurator, config config.Config) error {
+	changes, err := diffBucket(bucket, config)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		logger.Infof("Bucket settings are up to date")
+	}
+	for _, change := range changes {
+		logger.Infof("Updating %s: %s -> %s", change.Setting, change.From, change.To)
+		if err := change.apply(); err != nil {
+			return fmt.Errorf("Error updating %s of bucket %s: %v", change.Setting, config.Bucket, err)
+		}
+	}
+	return nil
+}
+
+// diffBucket compares every configured setting with the bucket.
+func diffBucket(bucket BucketConfigurator, config config.Config) ([]BucketChange, error) {
+	var changes []BucketChange
+
+	if config.Website.Enabled {
+		current, err := bucket.GetBucketWebsite()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading website configuration: %v", err)
+		}
+		desired := types.WebsiteConfig{
+			IndexDocument: config.Website.IndexDocument,
+			ErrorDocument: config.Website.ErrorDocument,
+			RoutingRules:  config.Website.RoutingRules,
+		}
+		if current == nil || !reflect.DeepEqual(normalizeWebsite(*current), normalizeWebsite(desired)) {
+			changes = append(changes, BucketChange{
+				Setting: "website",
+				From:    describeWebsite(current),
+				To:      describeWebsite(&desired),
+				apply:   func() error { return bucket.PutBucketWebsite(desired) },
+			})
+		}
+	}
+
+	if config.Website.PublicRead {
+		current, err := bucket.GetBucketPolicy()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading bucket policy: %v", err)
+		}
+		account, err := bucket.AccountID()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading account ID: %v", err)
+		}
+		policy, changed, err := withPublicRead(current, config.Bucket, account)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			from := "no public read statement"
+			if strings.Contains(current, publicReadSid) {
+				from = "outdated public read statement"
+			}
+			changes = append(changes, BucketChange{
+				Setting: "policy",
+				From:    from,
+				To:      "public read statements " + publicReadSid + ", " + privateInternalSid,
+				apply:   func() error { return bucket.PutBucketPolicy(policy) },
+			})
+		}
+	}
+
+	if len(config.Website.CORSRules) > 0 {
+		current, err := bucket.GetBucketCORS()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading CORS configuration: %v", err)
+		}
+		desired := config.Website.CORSRules
+		if !reflect.DeepEqual(normalizeCORS(current), normalizeCORS(desired)) {
+			changes = append(changes, BucketChange{
+				Setting: "cors",
+				From:    fmt.Sprintf("%d rules", len(current)),
+				To:      fmt.Sprintf("%d rules", len(desired)),
+				apply:   func() error { return bucket.PutBucketCORS(desired) },
+			})
+		}
+	}
+	return changes, nil
+}
+
+func describeWebsite(website *types.WebsiteConfig) string {
+	if website == nil {
+		return "disabled"
+	}
+	s := "index " + website.IndexDocument
+	if website.ErrorDocument != "" {
+		s += ", error " + website.ErrorDocument
+	}
+	if len(website.RoutingRules) > 0 {
+		s += fmt.Sprintf(", %d routing rules", len(website.RoutingRules))
+	}
+	return s
+}
+
+// normalizeWebsite drops empty conditions so that a configuration read
+// from the bucket compares equal to the one it was written from.
+func normalizeWebsite(website types.WebsiteConfig) types.WebsiteConfig {
+	var rules []types.RoutingRule
+	for _, rule := range website.RoutingRules {
+		if rule.Condition != nil && *rule.Condition == (types.RoutingCondition{}) {
+			rule.Condition = nil
+		}
+		rules = append(rules, rule)
+	}
+	website.RoutingRules = rules
+	return website
+}
+
+func normalizeCORS(rules []types.CORSRule) []types.CORSRule {
+	var normalized []types.CORSRule
+	for _, rule := range rules {
+		for _, list := range []*[]string{&rule.AllowedOrigins, &rule.AllowedMethods, &rule.AllowedHeaders, &rule.ExposeHeaders} {
+			if len(*list) == 0 {
+				*list = nil
+			}
+		}
+		normalized = append(normalized, rule)
+	}
+	return normalized
+}
+
+// withPublicRead adds the public read statements to policy, or replaces
+// different ones with the same Sid. It reports whether policy changed.
+// Internal keys, such as the manifests and deploy histories of every
+// prefix, stay private to account.
+func withPublicRead(policy, bucket, account string) (string, bool, error) {
+	managed := []map[string]any{
+		{
+			"Sid":       publicReadSid,
+			"Effect":    "Allow",
+			"Principal": "*",
+			"Action":    "s3:GetObject",
+			"Resource":  fmt.Sprintf("arn:aws:s3:::%s/*", bucket),
+		},
+		{
+			"Sid":       privateInternalSid,
+			"Effect":    "Deny",
+			"Principal": "*",
+			"Action":    "s3:GetObject",
+			"Resource": []any{
+				fmt.Sprintf("arn:aws:s3:::%s/%s", bucket, IncrementalConfig),
+				fmt.Sprintf("arn:aws:s3:::%s/*/%s", bucket, IncrementalConfig),
+				fmt.Sprintf("arn:aws:s3:::%s/%s*", bucket, DeploysPrefix),
+				fmt.Sprintf("arn:aws:s3:::%s/*/%s*", bucket, DeploysPrefix),
+			},
+			"Condition": map[string]any{
+				"StringNotEquals": map[string]any{"aws:PrincipalAccount": account},
+			},
+		},
+	}
+
+	document := map[string]any{"Version": "2012-10-17"}
+	if policy != "" {
+		if err := json.Unmarshal([]byte(policy), &document); err != nil {
+			return "", false, fmt.Errorf("Error parsing bucket policy: %v", err)
+		}
+	}
+
+	var statements []any
+	switch s := document["Statement"].(type) {
+	case []any:
+		statements = s
+	case map[string]any:
+		statements = []any{s}
+	}
+	changed := false
+	for _, statement := range managed {
+		found := false
+		for i, s := range statements {
+			if existing, ok := s.(map[string]any); ok && existing["Sid"] == statement["Sid"] {
+				if !reflect.DeepEqual(existing, statement) {
+					statements[i] = statement
+					changed = true
+				}
+				found = true
+			}
+		}
+		if !found {
+			statements = append(statements, statement)
+			changed = true
+		}
+	}
+	if !changed {
+		return policy, false, nil
+	}
+	document["Statement"] = statements
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
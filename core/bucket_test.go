@@ -0,0 +1,60 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWithPublicRead(t *testing.T) {
+	existing := `{"Version":"2012-10-17","Statement":[{"Sid":"Other","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::111122223333:root"},"Action":"s3:PutObject","Resource":"arn:aws:s3:::site/*"}]}`
+
+	policy, changed, err := withPublicRead(existing, "site", "111122223333")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("policy not changed")
+	}
+
+	var document struct {
+		Statement []struct {
+			Sid       string
+			Effect    string
+			Resource  any
+			Condition map[string]map[string]string
+		}
+	}
+	if err := json.Unmarshal([]byte(policy), &document); err != nil {
+		t.Fatal(err)
+	}
+	if len(document.Statement) != 3 || document.Statement[0].Sid != "Other" {
+		t.Fatalf("statements = %+v, want the existing one kept and two added", document.Statement)
+	}
+	deny := document.Statement[2]
+	if deny.Sid != privateInternalSid || deny.Effect != "Deny" {
+		t.Errorf("statement = %+v, want %s denying", deny, privateInternalSid)
+	}
+	want := []any{
+		"arn:aws:s3:::site/.incremental",
+		"arn:aws:s3:::site/*/.incremental",
+		"arn:aws:s3:::site/.deploys/*",
+		"arn:aws:s3:::site/*/.deploys/*",
+	}
+	if resources, _ := deny.Resource.([]any); len(resources) != len(want) {
+		t.Errorf("resources = %v, want %v", deny.Resource, want)
+	} else {
+		for i := range want {
+			if resources[i] != want[i] {
+				t.Errorf("resources = %v, want %v", resources, want)
+				break
+			}
+		}
+	}
+	if account := deny.Condition["StringNotEquals"]["aws:PrincipalAccount"]; account != "111122223333" {
+		t.Errorf("deny applies to accounts other than %q, want 111122223333", account)
+	}
+
+	if _, changed, err := withPublicRead(policy, "site", "111122223333"); err != nil || changed {
+		t.Errorf("second call changed = %v, err = %v, want an unchanged policy", changed, err)
+	}
+}
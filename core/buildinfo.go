@@ -0,0 +1,48 @@
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/types"
+	"github.com/rizaldntr/storage-service-website-action/utils"
+)
+
+// BuildInfoKey is the object written by the build-info transform.
+const BuildInfoKey = "build-info.json"
+
+func init() {
+	RegisterSiteTransformer("build-info", func(cfg config.Config) (SiteTransformer, error) {
+		return buildInfoTransformer{fileConfig: cfg.FileConfig, environment: cfg.Environment}, nil
+	})
+}
+
+// BuildInfo describes the commit a site was deployed from. It only changes
+// with the commit, so redeploying one does not upload it again.
+type BuildInfo struct {
+	Repository  string `json:"repository,omitempty"`
+	Commit      string `json:"commit,omitempty"`
+	Ref         string `json:"ref,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// buildInfoTransformer adds BuildInfoKey to the files, for pages and
+// monitoring to tell which commit is live.
+type buildInfoTransformer struct {
+	fileConfig  config.FileConfig
+	environment string
+}
+
+func (t buildInfoTransformer) TransformSite(files []types.FileInfo) ([]types.FileInfo, error) {
+	workflow := utils.CurrentWorkflow()
+	data, err := json.MarshalIndent(BuildInfo{
+		Repository:  workflow.Repository,
+		Commit:      workflow.SHA,
+		Ref:         workflow.Ref,
+		Environment: t.environment,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(files, GeneratedFile(t.fileConfig, BuildInfoKey, append(data, '\n'))), nil
+}
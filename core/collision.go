@@ -0,0 +1,124 @@
+package core
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// ErrKeyCollision is returned by ListFiles when files written to the same
+// key cannot be resolved by the collision policy.
+var ErrKeyCollision = errors.New("key collision")
+
+// Collision is a key written by more than one file.
+type Collision struct {
+	Key     string   `json:"key"`
+	Sources []string `json:"sources"`
+	// Winner is the source uploaded to the key, empty when the collision
+	// could not be resolved.
+	Winner string `json:"winner,omitempty"`
+}
+
+// resolveCollisions keeps one file for each key of files, which are sorted
+// by key, and returns the keys that had more than one.
+func resolveCollisions(files []types.FileInfo, config config.Config) ([]types.FileInfo, []Collision) {
+	// Mounts are ordered by folder, a folder mounted twice collides with
+	// itself on the same source anyway.
+	order := map[string]int{}
+	for i, mount := range config.Mounts {
+		if _, ok := order[mount.Folder]; !ok {
+			order[mount.Folder] = i
+		}
+	}
+
+	var collisions []Collision
+	resolved := files[:0]
+	for start := 0; start < len(files); {
+		end := start + 1
+		for end < len(files) && files[end].TargetPath == files[start].TargetPath {
+			end++
+		}
+		group := files[start:end]
+		start = end
+		if len(group) == 1 {
+			resolved = append(resolved, group[0])
+			continue
+		}
+
+		collision := Collision{Key: group[0].TargetPath}
+		winner, ok := group[0], true
+		for _, file := range group {
+			collision.Sources = append(collision.Sources, describeSource(file))
+		}
+		for _, file := range group[1:] {
+			wins, decided := prefer(file, winner, config.CollisionPolicy, config.FileConfig.IndexDocument, order)
+			if !decided {
+				ok = false
+				continue
+			}
+			if wins {
+				winner = file
+			}
+		}
+
+		if ok {
+			collision.Winner = describeSource(winner)
+			logger.Warningf("Key %s is written by %s, keeping %s", collision.Key, strings.Join(collision.Sources, ", "), collision.Winner)
+		} else {
+			// keep a file for the plan, the deploy stops anyway
+			winner = group[0]
+			logger.Errorf("Key %s is written by %s", collision.Key, strings.Join(collision.Sources, ", "))
+		}
+		collisions = append(collisions, collision)
+		resolved = append(resolved, winner)
+	}
+	return resolved, collisions
+}
+
+// prefer reports whether file wins the key over current, and whether the
+// collision policy decides between them at all.
+func prefer(file, current types.FileInfo, policy config.CollisionPolicy, indexDocument string, order map[string]int) (wins, decided bool) {
+	if policy == config.FailOnCollision || file.SourcePath == current.SourcePath {
+		return false, false
+	}
+	if file.Dir != current.Dir {
+		switch policy {
+		case config.FirstMount:
+			return order[file.Dir] < order[current.Dir], true
+		case config.LastMount:
+			return order[file.Dir] > order[current.Dir], true
+		}
+		return false, false
+	}
+	fileRank, currentRank := keyRank(file, indexDocument), keyRank(current, indexDocument)
+	if fileRank == currentRank {
+		return false, false
+	}
+	return fileRank < currentRank, true
+}
+
+// keyRank orders the files of a mount competing for a key, lowest first: a
+// file under its own name, a page without its extension, a directory index
+// and last a redirect.
+func keyRank(file types.FileInfo, indexDocument string) int {
+	switch {
+	case file.RedirectLocation != "":
+		return 3
+	case strings.HasSuffix(file.TargetPath, file.Name):
+		return 0
+	case file.Name == indexDocument:
+		return 2
+	}
+	return 1
+}
+
+// describeSource names the source of a file, marking redirects.
+func describeSource(file types.FileInfo) string {
+	if file.RedirectLocation != "" {
+		return file.SourcePath + " (redirect)"
+	}
+	return file.SourcePath
+}
@@ -1,26 +1,70 @@
 package core
 
 import (
+	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/IGLOU-EU/go-wildcard/v2"
 	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
 	"github.com/rizaldntr/storage-service-website-action/types"
 	"github.com/rizaldntr/storage-service-website-action/utils"
-	"github.com/sethvargo/go-githubactions"
 )
 
-var sema = make(chan struct{}, 20)
+// mountWalk is a mount being walked with its file settings.
+type mountWalk struct {
+	folder string
+	prefix string
+	config config.FileConfig
+}
+
+// walkItem is a directory of a mount waiting to be read.
+type walkItem struct {
+	dir   string
+	mount *mountWalk
+}
 
+// WalkDir lists every file below the folders of config.Mounts. Directories
+// are read by a fixed pool of workers; files are emitted without their MD5,
+// see HashFiles.
 func WalkDir(config config.Config) <-chan types.FileInfo {
-	files := make(chan types.FileInfo)
+	workers := max(config.Concurrency.Walk, 1)
+	files := make(chan types.FileInfo, workers)
+
+	roots := make([]walkItem, 0, len(config.Mounts))
+	for _, mount := range config.Mounts {
+		roots = append(roots, walkItem{
+			dir: mount.Folder,
+			mount: &mountWalk{
+				folder: mount.Folder,
+				prefix: mount.Prefix,
+				config: config.MountFileConfig(mount),
+			},
+		})
+	}
+	queue := newDirQueue(roots...)
+
 	var sw sync.WaitGroup
-	sw.Add(1)
-	go walkDir(config.Folder, config.Folder, &sw, config.FileConfig, files)
+	sw.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer sw.Done()
+			for {
+				item, ok := queue.pop()
+				if !ok {
+					return
+				}
+				walkDir(item.dir, item.mount, queue, files)
+				queue.done()
+			}
+		}()
+	}
 	go func() {
 		sw.Wait()
 		close(files)
@@ -28,67 +72,223 @@ func WalkDir(config config.Config) <-chan types.FileInfo {
 	return files
 }
 
-func walkDir(dir, root string, sw *sync.WaitGroup, config config.FileConfig, files chan<- types.FileInfo) {
-	defer sw.Done()
+// ListFiles walks every mount and returns the files sorted by key, with the
+// keys written by more than one file. Those are resolved by
+// config.CollisionPolicy; when some cannot be, the files are returned with
+// an error wrapping ErrKeyCollision, keeping the first source of each such
+// key. The configured transforms are then applied. Walking is cheap next to
+// hashing and uploading, so the listing is completed before anything is
+// written.
+func ListFiles(config config.Config) ([]types.FileInfo, []Collision, error) {
+	// A missing folder would otherwise delete everything under its prefix
+	// as leftovers.
+	for _, mount := range config.Mounts {
+		if _, err := os.Stat(mount.Folder); err != nil {
+			return nil, nil, fmt.Errorf("Error reading mount %s: %v", mount.Folder, err)
+		}
+	}
 
+	var files []types.FileInfo
+	for file := range WalkDir(config) {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(a, b int) bool {
+		if files[a].TargetPath != files[b].TargetPath {
+			return files[a].TargetPath < files[b].TargetPath
+		}
+		return files[a].SourcePath < files[b].SourcePath
+	})
+
+	files, collisions := resolveCollisions(files, config)
+	if len(config.Transforms) > 0 {
+		transformed, err := transform(files, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = transformed
+	}
+	unresolved := 0
+	for _, collision := range collisions {
+		if collision.Winner == "" {
+			unresolved++
+		}
+	}
+	if unresolved > 0 {
+		return files, collisions, fmt.Errorf("Found %d keys written by more than one file: %w", unresolved, ErrKeyCollision)
+	}
+	return files, collisions, nil
+}
+
+// emit streams files to the first stage of a pipeline.
+func emit(files []types.FileInfo) <-chan types.FileInfo {
+	out := make(chan types.FileInfo)
+	go func() {
+		defer close(out)
+		for _, file := range files {
+			out <- file
+		}
+	}()
+	return out
+}
+
+func walkDir(dir string, mount *mountWalk, queue *dirQueue, files chan<- types.FileInfo) {
+	config, root := mount.config, mount.folder
 	for _, entry := range dirents(dir) {
 		if entry.IsDir() {
-			sw.Add(1)
-			subDir := filepath.Join(dir, entry.Name())
-			go walkDir(subDir, root, sw, config, files)
+			queue.push(walkItem{dir: filepath.Join(dir, entry.Name()), mount: mount})
 		} else {
 			path := filepath.Join(dir, entry.Name())
 			if isExcluded(path, config) {
 				continue
 			}
 
-			md5, err := utils.HashMD5(path)
-			if err != nil {
-				githubactions.Debugf("Failed to compute MD5 hash for file: %v", err)
+			var size int64
+			if info, err := entry.Info(); err == nil {
+				size = info.Size()
 			}
 
 			targetPath := strings.TrimPrefix(path, root)
 			file := types.FileInfo{
 				ACL:          config.DefaultACL,
 				CacheControl: config.DefaultCacheControl,
-				ContentMD5:   md5,
 				Dir:          root,
 				Name:         entry.Name(),
 				SourcePath:   path,
 				TargetPath:   targetPath,
+				Size:         size,
 			}
 			setCacheControlAndFileType(config, &file)
 			processRegexConfig(&file, config.ObjectRules)
 
-			if file.FileType == types.HTML && config.RemoveHTMLExtension && targetPath != "index.html" {
-				file.TargetPath = strings.TrimSuffix(targetPath, ".html")
-			} else if file.FileType == types.HTML && config.DuplicateHTMLWithNoExtension && targetPath != "index.html" {
-				targetPath = strings.TrimSuffix(targetPath, ".html")
-				duplicated := file
-				duplicated.TargetPath = targetPath
-				files <- duplicated
+			if file.FileType != types.HTML {
+				file.TargetPath = mount.prefix + targetPath
+				files <- file
+				continue
+			}
+			keys, redirects := htmlKeys(config, targetPath)
+			for _, key := range keys {
+				file.TargetPath = mount.prefix + key
+				files <- file
+			}
+			for key, location := range redirects {
+				redirect := file
+				redirect.TargetPath = mount.prefix + key
+				redirect.RedirectLocation = "/" + mount.prefix + location
+				redirect.Size = 0
+				files <- redirect
 			}
-			files <- file
 		}
 	}
 }
 
-func dirents(dir string) []fs.DirEntry {
-	sema <- struct{}{}
-	defer func() { <-sema }()
+// htmlKeys returns the keys an HTML page at key is uploaded to, and the
+// redirects created for it from their key to the key they redirect to.
+// Keys are relative to the mount. The root index document is never renamed.
+func htmlKeys(fileConfig config.FileConfig, key string) ([]string, map[string]string) {
+	if key == fileConfig.IndexDocument {
+		return []string{key}, nil
+	}
+
+	name := path.Base(key)
+	if name != fileConfig.IndexDocument {
+		bare := strings.TrimSuffix(key, path.Ext(key))
+		switch {
+		case fileConfig.RemoveHTMLExtension:
+			return []string{bare}, nil
+		case fileConfig.DuplicateHTMLWithNoExtension:
+			return []string{key, bare}, nil
+		}
+		return []string{key}, nil
+	}
+
+	// a nested index document, e.g. docs/index.html
+	dir := strings.TrimSuffix(key, name)
+	bare := strings.TrimSuffix(dir, "/")
+	var keys []string
+	if fileConfig.IndexMode == config.KeepIndex || !fileConfig.RemoveHTMLExtension {
+		keys = append(keys, key)
+	}
+	switch fileConfig.IndexMode {
+	case config.SlashIndex:
+		keys = append(keys, dir)
+	case config.BareIndex:
+		keys = append(keys, bare)
+	case config.BothIndex:
+		keys = append(keys, dir, bare)
+	}
 
+	switch fileConfig.TrailingSlash {
+	case config.AddTrailingSlash:
+		return keys, map[string]string{bare: dir}
+	case config.RemoveTrailingSlash:
+		return keys, map[string]string{dir: bare}
+	}
+	return keys, nil
+}
+
+func dirents(dir string) []fs.DirEntry {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		githubactions.Errorf("Unable to read directory: %v", err)
+		logger.Errorf("Unable to read directory: %v", err)
 		return nil
 	}
 	return entries
 }
 
+// dirQueue hands out directories to walk workers. It is drained once no
+// directory is queued and no worker is still reading one.
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	dirs    []walkItem
+	pending int
+}
+
+func newDirQueue(roots ...walkItem) *dirQueue {
+	q := &dirQueue{dirs: roots, pending: len(roots)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *dirQueue) push(dir walkItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.dirs = append(q.dirs, dir)
+	q.pending++
+	q.cond.Signal()
+}
+
+func (q *dirQueue) pop() (walkItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.dirs) == 0 && q.pending > 0 {
+		q.cond.Wait()
+	}
+	if len(q.dirs) == 0 {
+		return walkItem{}, false
+	}
+	dir := q.dirs[len(q.dirs)-1]
+	q.dirs = q.dirs[:len(q.dirs)-1]
+	return dir, true
+}
+
+// done marks a popped directory as fully read.
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending--
+	if q.pending == 0 {
+		q.cond.Broadcast()
+	}
+}
+
 func isExcluded(path string, config config.FileConfig) bool {
 	for _, pattern := range config.ExcludePatterns {
 		if wildcard.Match(pattern, path) {
-			githubactions.Infof("Excluding %s based on pattern %s", path, pattern)
+			logger.Infof("Excluding %s based on pattern %s", path, pattern)
 			return true
 		}
 	}
@@ -97,8 +297,13 @@ func isExcluded(path string, config config.FileConfig) bool {
 
 func processRegexConfig(file *types.FileInfo, regexConfigs []config.ObjectRule) {
 	var regexConfig *config.ObjectRule
+	key := strings.TrimPrefix(file.SourcePath, file.Dir)
+	if file.SourcePath == "" {
+		// generated by a transform
+		key = file.TargetPath
+	}
 	for _, config := range regexConfigs {
-		if wildcard.Match(config.Pattern, strings.TrimPrefix(file.SourcePath, file.Dir)) {
+		if wildcard.Match(config.Pattern, key) {
 			regexConfig = &config
 			break
 		}
@@ -115,7 +320,7 @@ func processRegexConfig(file *types.FileInfo, regexConfigs []config.ObjectRule)
 }
 
 func setCacheControlAndFileType(config config.FileConfig, file *types.FileInfo) {
-	path := file.SourcePath
+	path := file.Name
 	switch {
 	case utils.IsHTML(path):
 		file.CacheControl = config.DefaultHTMLCacheControl
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// fileKeys returns the keys of files, redirects marked with their location.
+func fileKeys(files []types.FileInfo) []string {
+	keys := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.RedirectLocation != "" {
+			keys = append(keys, file.TargetPath+" -> "+file.RedirectLocation)
+			continue
+		}
+		keys = append(keys, file.TargetPath)
+	}
+	return keys
+}
+
+func TestListFilesMissingFolder(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "dist")
+	site := writeFolder(t, map[string]string{"index.html": "<p>root</p>"})
+	tests := []struct {
+		name   string
+		values config.Values
+	}{
+		{"folder", config.Values{"FOLDER": missing}},
+		{"single mount", config.Values{"MOUNTS": fmt.Sprintf("- folder: %s\n  prefix: docs\n", missing)}},
+		{"one of several mounts", config.Values{"MOUNTS": fmt.Sprintf("- folder: %s\n- folder: %s\n  prefix: docs\n", site, missing)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ListFiles(testConfig(t, tt.values))
+			if err == nil || !strings.Contains(err.Error(), "Error reading mount "+missing) {
+				t.Errorf("ListFiles() error = %v, want the missing folder reported", err)
+			}
+		})
+	}
+}
+
+func TestListFilesMounts(t *testing.T) {
+	site := writeFolder(t, map[string]string{
+		"index.html": "<p>root</p>",
+		"app.js":     "app()",
+		"app.js.map": "{}",
+	})
+	docs := writeFolder(t, map[string]string{
+		"index.html":      "<p>docs</p>",
+		"guide/intro.txt": "intro",
+		"draft.txt":       "draft",
+		"app.js.map":      "{}",
+	})
+	mounts := fmt.Sprintf(`
+- folder: %s
+  exclude: ["*.map"]
+- folder: %s
+  prefix: /docs/
+  exclude: ["*draft*"]
+`, site, docs)
+
+	files, _, err := ListFiles(testConfig(t, config.Values{"MOUNTS": mounts}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"app.js", "docs/app.js.map", "docs/guide/intro.txt", "docs/index.html", "index.html"}
+	if got := fileKeys(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+}
+
+func TestListFilesCollisions(t *testing.T) {
+	page := writeFolder(t, map[string]string{
+		"index.html": "<p>root</p>",
+		"about.html": "<p>about</p>",
+		"about":      "about as a file",
+	})
+	first := writeFolder(t, map[string]string{"a.txt": "first"})
+	last := writeFolder(t, map[string]string{"a.txt": "last"})
+	mounts := fmt.Sprintf("- folder: %s\n- folder: %s\n", first, last)
+
+	tests := []struct {
+		name   string
+		values config.Values
+		keys   []string
+		key    string
+		// winner is the source kept at key, empty when the collision is
+		// not resolved
+		winner string
+	}{
+		{
+			name:   "extension removed",
+			values: config.Values{"FOLDER": page, "REMOVE_HTML_EXTENSION": "true"},
+			keys:   []string{"about", "index.html"},
+			key:    "about",
+			winner: filepath.Join(page, "about"),
+		},
+		{
+			name:   "duplicated without extension",
+			values: config.Values{"FOLDER": page, "DUPLICATE_HTML_WITH_NO_EXTENSION": "true"},
+			keys:   []string{"about", "about.html", "index.html"},
+			key:    "about",
+			winner: filepath.Join(page, "about"),
+		},
+		{
+			name:   "error policy",
+			values: config.Values{"FOLDER": page, "REMOVE_HTML_EXTENSION": "true", "COLLISION_POLICY": "error"},
+			keys:   []string{"about", "index.html"},
+			key:    "about",
+		},
+		{
+			name:   "first mount",
+			values: config.Values{"MOUNTS": mounts, "COLLISION_POLICY": "first"},
+			keys:   []string{"a.txt"},
+			key:    "a.txt",
+			winner: filepath.Join(first, "a.txt"),
+		},
+		{
+			name:   "last mount",
+			values: config.Values{"MOUNTS": mounts, "COLLISION_POLICY": "last"},
+			keys:   []string{"a.txt"},
+			key:    "a.txt",
+			winner: filepath.Join(last, "a.txt"),
+		},
+		{
+			name:   "mounts under prefer-file",
+			values: config.Values{"MOUNTS": mounts},
+			keys:   []string{"a.txt"},
+			key:    "a.txt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, collisions, err := ListFiles(testConfig(t, tt.values))
+			if tt.winner == "" && !errors.Is(err, ErrKeyCollision) {
+				t.Errorf("ListFiles() error = %v, want %v", err, ErrKeyCollision)
+			}
+			if tt.winner != "" && err != nil {
+				t.Fatal(err)
+			}
+			if got := fileKeys(files); !reflect.DeepEqual(got, tt.keys) {
+				t.Errorf("keys = %v, want %v", got, tt.keys)
+			}
+			if len(collisions) != 1 || collisions[0].Key != tt.key || len(collisions[0].Sources) != 2 {
+				t.Fatalf("collisions = %+v, want one at %s", collisions, tt.key)
+			}
+			if collisions[0].Winner != tt.winner {
+				t.Errorf("winner = %q, want %q", collisions[0].Winner, tt.winner)
+			}
+			for _, file := range files {
+				if file.TargetPath == tt.key && tt.winner != "" && file.SourcePath != tt.winner {
+					t.Errorf("%s is uploaded from %s, want %s", tt.key, file.SourcePath, tt.winner)
+				}
+			}
+		})
+	}
+}
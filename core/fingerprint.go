@@ -0,0 +1,276 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/IGLOU-EU/go-wildcard/v2"
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+var (
+	// htmlReference matches the attributes of HTML tags that load a URL.
+	htmlReference = regexp.MustCompile(`(?i)(\s(?:src|href|poster|srcset|data-src)\s*=\s*)("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	// cssReference matches url() values and @import strings, in CSS files
+	// and in the style of HTML pages.
+	cssReference = regexp.MustCompile(`(url\(\s*)("[^"]*"|'[^']*'|[^)\s]*)|(@import\s+)("[^"]*"|'[^']*')`)
+)
+
+func init() {
+	RegisterSiteTransformer("fingerprint", func(cfg config.Config) (SiteTransformer, error) {
+		return fingerprintTransformer{fpConfig: cfg.Fingerprint, indexDocument: cfg.FileConfig.IndexDocument}, nil
+	})
+}
+
+// fingerprintTransformer is the fingerprint transform. It sees every file at
+// once, as pages are rewritten with the names of the assets they load.
+type fingerprintTransformer struct {
+	fpConfig      config.FingerprintConfig
+	indexDocument string
+}
+
+func (t fingerprintTransformer) TransformSite(files []types.FileInfo) ([]types.FileInfo, error) {
+	return files, fingerprint(files, t.fpConfig, t.indexDocument)
+}
+
+// fingerprinter renames assets to name.<hash>.ext as they are found
+// referenced by pages and stylesheets. An asset nothing refers to keeps its
+// name, as its URL may be known elsewhere, e.g. /favicon.ico.
+type fingerprinter struct {
+	cacheControl string
+	// assets are the files that may be renamed, by key.
+	assets  map[string]*types.FileInfo
+	renamed map[string]string
+	// visiting holds the stylesheets being renamed, to detect cycles.
+	visiting map[string]bool
+	// rewritten holds the sources already rewritten by sourceKey, pages are
+	// read once for all their aliases.
+	rewritten map[string][]byte
+	err       error
+}
+
+// fingerprint renames the assets of files matching the configured patterns
+// and rewrites the references to them in HTML and CSS files. Rewritten files
+// carry their new content, so that the manifest records its digest.
+func fingerprint(files []types.FileInfo, fpConfig config.FingerprintConfig, indexDocument string) error {
+	f := &fingerprinter{
+		cacheControl: fpConfig.CacheControl,
+		assets:       map[string]*types.FileInfo{},
+		renamed:      map[string]string{},
+		visiting:     map[string]bool{},
+		rewritten:    map[string][]byte{},
+	}
+	for i, file := range files {
+		if file.RedirectLocation != "" || file.FileType == types.HTML {
+			continue
+		}
+		for _, pattern := range fpConfig.Patterns {
+			if wildcard.Match(pattern, file.TargetPath) {
+				f.assets[file.TargetPath] = &files[i]
+				break
+			}
+		}
+	}
+
+	for i := range files {
+		file := &files[i]
+		if file.RedirectLocation != "" || file.FileType != types.HTML {
+			continue
+		}
+		content, ok := f.rewritten[sourceKey(*file)]
+		if !ok {
+			content = f.rewrite(f.read(file), referenceBase(*file, indexDocument), true)
+			f.rewritten[sourceKey(*file)] = content
+		}
+		// nil keeps the content of earlier transforms, e.g. minified
+		if content != nil {
+			file.Content = content
+		}
+	}
+	// stylesheets no page links to may still refer to renamed assets
+	for i := range files {
+		file := &files[i]
+		if _, ok := f.rewritten[file.SourcePath]; !ok && isStylesheet(*file) {
+			f.rewriteStylesheet(file, path.Dir(file.TargetPath))
+		}
+	}
+	if f.err != nil {
+		return f.err
+	}
+	renamed := 0
+	for key, to := range f.renamed {
+		if key != to {
+			renamed++
+		}
+	}
+	logger.Infof("Fingerprinted %d assets", renamed)
+	return nil
+}
+
+// rename returns the fingerprinted key of the asset at key, renaming it on
+// first use. Stylesheets are rewritten first, so their hash covers the new
+// names of what they load.
+func (f *fingerprinter) rename(key string) (string, bool) {
+	if renamed, ok := f.renamed[key]; ok {
+		return renamed, renamed != key
+	}
+	file, ok := f.assets[key]
+	if !ok {
+		return "", false
+	}
+	if f.visiting[key] {
+		// stylesheets importing each other keep the name of the one the
+		// cycle is closed on, its references are still rewritten
+		logger.Warningf("Not fingerprinting %s, it is part of an import cycle", key)
+		f.renamed[key] = key
+		return "", false
+	}
+
+	f.visiting[key] = true
+	content := f.read(file)
+	if isStylesheet(*file) {
+		content = f.rewriteStylesheet(file, path.Dir(key))
+	}
+	f.visiting[key] = false
+	if _, ok := f.renamed[key]; ok {
+		return "", false
+	}
+
+	sum := sha256.Sum256(content)
+	ext := path.Ext(key)
+	renamed := strings.TrimSuffix(key, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+	logger.Debugf("Fingerprinting %s as %s", key, renamed)
+	file.TargetPath = renamed
+	file.CacheControl = f.cacheControl
+	f.renamed[key] = renamed
+	return renamed, true
+}
+
+// rewriteStylesheet rewrites file and returns its content to upload.
+func (f *fingerprinter) rewriteStylesheet(file *types.FileInfo, base string) []byte {
+	original := f.read(file)
+	content := f.rewrite(original, base, false)
+	f.rewritten[file.SourcePath] = content
+	if content == nil {
+		return original
+	}
+	file.Content = content
+	return content
+}
+
+// rewrite replaces the references to assets in content, resolving relative
+// ones against the base key directory. It returns nil when nothing was
+// replaced, so that the file is uploaded from disk.
+func (f *fingerprinter) rewrite(content []byte, base string, html bool) []byte {
+	replace := func(value string, srcset bool) string {
+		quote := ""
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			quote, value = value[:1], value[1:len(value)-1]
+		}
+		if !srcset {
+			value, _ = f.reference(value, base)
+			return quote + value + quote
+		}
+		candidates := strings.Split(value, ",")
+		for i, candidate := range candidates {
+			fields := strings.Fields(candidate)
+			if len(fields) > 0 {
+				if ref, ok := f.reference(fields[0], base); ok {
+					candidates[i] = strings.Replace(candidate, fields[0], ref, 1)
+				}
+			}
+		}
+		return quote + strings.Join(candidates, ",") + quote
+	}
+
+	s := string(content)
+	s = cssReference.ReplaceAllStringFunc(s, func(match string) string {
+		m := cssReference.FindStringSubmatch(match)
+		if m[1] != "" {
+			return m[1] + replace(m[2], false)
+		}
+		return m[3] + replace(m[4], false)
+	})
+	if html {
+		s = htmlReference.ReplaceAllStringFunc(s, func(match string) string {
+			m := htmlReference.FindStringSubmatch(match)
+			return m[1] + replace(m[2], strings.Contains(strings.ToLower(m[1]), "srcset"))
+		})
+	}
+	if s == string(content) {
+		return nil
+	}
+	return []byte(s)
+}
+
+// reference returns ref pointing at the fingerprinted asset it refers to,
+// keeping its query and fragment. Other references are left as they are.
+func (f *fingerprinter) reference(ref, base string) (string, bool) {
+	key, ok := referenceKey(ref, base)
+	if !ok {
+		return ref, false
+	}
+	renamed, ok := f.rename(key)
+	if !ok {
+		return ref, false
+	}
+
+	refPath, suffix := ref, ""
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		refPath, suffix = ref[:i], ref[i:]
+	}
+	name := path.Base(renamed)
+	if unescaped, err := url.PathUnescape(refPath); err == nil && unescaped != refPath {
+		name = url.PathEscape(name)
+	}
+	return refPath[:strings.LastIndex(refPath, "/")+1] + name + suffix, true
+}
+
+// referenceKey returns the key a reference to the site points at, resolving
+// relative ones against the base key directory.
+func referenceKey(ref, base string) (string, bool) {
+	if ref == "" || strings.HasPrefix(ref, "//") || strings.HasPrefix(ref, "#") {
+		return "", false
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	if strings.HasPrefix(u.Path, "/") {
+		return strings.TrimPrefix(u.Path, "/"), true
+	}
+	return path.Join(base, u.Path), true
+}
+
+func (f *fingerprinter) read(file *types.FileInfo) []byte {
+	if file.Content != nil {
+		return file.Content
+	}
+	content, err := os.ReadFile(file.SourcePath)
+	if err != nil && f.err == nil {
+		f.err = fmt.Errorf("Error reading file %s: %v", file.SourcePath, err)
+	}
+	return content
+}
+
+// referenceBase is the key directory relative references of a page are
+// written against, that of its source file whatever key it is served at.
+func referenceBase(file types.FileInfo, indexDocument string) string {
+	if !strings.HasSuffix(file.TargetPath, file.Name) && file.Name == indexDocument {
+		// docs/ or docs, for docs/index.html
+		return strings.TrimSuffix(file.TargetPath, "/")
+	}
+	return path.Dir(file.TargetPath)
+}
+
+func isStylesheet(file types.FileInfo) bool {
+	return file.RedirectLocation == "" && strings.EqualFold(path.Ext(file.SourcePath), ".css")
+}
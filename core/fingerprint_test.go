@@ -0,0 +1,216 @@
+package core
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// fingerprinted matches a key renamed by the fingerprint transform.
+var fingerprinted = regexp.MustCompile(`\.[0-9a-f]{8}\.[a-z0-9]+$`)
+
+// transformedFiles lists the files of site with the given settings, by
+// source path relative to the folder.
+func transformedFiles(t *testing.T, site map[string]string, values config.Values) map[string]types.FileInfo {
+	t.Helper()
+	folder := writeFolder(t, site)
+	values["FOLDER"] = folder
+	files, _, err := ListFiles(testConfig(t, values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bySource := map[string]types.FileInfo{}
+	for _, file := range files {
+		source := strings.TrimPrefix(file.SourcePath, folder+"/")
+		if _, ok := bySource[source]; !ok {
+			bySource[source] = file
+		}
+	}
+	return bySource
+}
+
+// uploaded returns the content uploaded for file.
+func uploaded(t *testing.T, file types.FileInfo) string {
+	t.Helper()
+	content, err := fileContent(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name string
+		site map[string]string
+		// renamed are the sources expected under a fingerprinted key,
+		// referred to by the source given
+		renamed map[string]string
+		// kept are the sources keeping their key
+		kept []string
+	}{
+		{
+			name: "page references",
+			site: map[string]string{
+				"index.html":  `<link rel="stylesheet" href="style.css"><script src="/js/app.js?v=1#main"></script>`,
+				"style.css":   `body{color:red}`,
+				"js/app.js":   `app()`,
+				"favicon.ico": `icon`,
+			},
+			renamed: map[string]string{"style.css": "index.html", "js/app.js": "index.html"},
+			kept:    []string{"favicon.ico", "index.html"},
+		},
+		{
+			name: "relative to a nested page",
+			site: map[string]string{
+				"docs/index.html": `<img src="../img/logo.png"><img src="local.png">`,
+				"img/logo.png":    `logo`,
+				"docs/local.png":  `local`,
+			},
+			renamed: map[string]string{"img/logo.png": "docs/index.html", "docs/local.png": "docs/index.html"},
+		},
+		{
+			name: "srcset",
+			site: map[string]string{
+				"index.html": `<img srcset="small.png 1x, large.png 2x" src="small.png">`,
+				"small.png":  `small`,
+				"large.png":  `large`,
+			},
+			renamed: map[string]string{"small.png": "index.html", "large.png": "index.html"},
+		},
+		{
+			name: "stylesheet url and import",
+			site: map[string]string{
+				"index.html":    `<link rel="stylesheet" href="css/site.css">`,
+				"css/site.css":  `@import "base.css"; body{background:url(../img/bg.png)}`,
+				"css/base.css":  `html{margin:0}`,
+				"img/bg.png":    `bg`,
+				"css/other.css": `a{background:url('/img/bg.png')}`,
+			},
+			renamed: map[string]string{
+				"css/site.css": "index.html",
+				"css/base.css": "css/site.css",
+				"img/bg.png":   "css/other.css",
+			},
+			kept: []string{"css/other.css"},
+		},
+		{
+			name: "page style",
+			site: map[string]string{
+				"index.html": `<div style="background: url(bg.png)"></div>`,
+				"bg.png":     `bg`,
+			},
+			renamed: map[string]string{"bg.png": "index.html"},
+		},
+		{
+			name: "import cycle",
+			site: map[string]string{
+				"index.html": `<link rel="stylesheet" href="a.css">`,
+				"a.css":      `@import "b.css";`,
+				"b.css":      `@import "a.css";`,
+			},
+			// the cycle is closed on a.css, which keeps its name
+			renamed: map[string]string{"b.css": "a.css"},
+			kept:    []string{"a.css"},
+		},
+		{
+			name: "external and unknown references",
+			site: map[string]string{
+				"index.html": `<script src="https://cdn.example.com/app.js"></script><script src="//cdn.example.com/lib.js"></script><a href="#top"></a><img src="missing.png">`,
+				"app.js":     `app()`,
+			},
+			kept: []string{"app.js", "index.html"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := transformedFiles(t, tt.site, config.Values{"FINGERPRINT": "true"})
+			for source, from := range tt.renamed {
+				file := files[source]
+				if !fingerprinted.MatchString(file.TargetPath) || path.Dir(file.TargetPath) != path.Dir(source) {
+					t.Errorf("%s is uploaded to %s, want a fingerprinted key", source, file.TargetPath)
+					continue
+				}
+				if file.CacheControl != "max-age=31536000, immutable" {
+					t.Errorf("%s has Cache-Control %q", source, file.CacheControl)
+				}
+				if content := uploaded(t, files[from]); !strings.Contains(content, path.Base(file.TargetPath)) {
+					t.Errorf("%s does not refer to %s: %s", from, file.TargetPath, content)
+				}
+			}
+			for _, source := range tt.kept {
+				if file := files[source]; file.TargetPath != source {
+					t.Errorf("%s is uploaded to %s, want its own key", source, file.TargetPath)
+				}
+			}
+			for source, file := range files {
+				if file.Content != nil && file.Size != int64(len(file.Content)) {
+					t.Errorf("%s has size %d for %d bytes of content", source, file.Size, len(file.Content))
+				}
+			}
+		})
+	}
+}
+
+func TestFingerprintKeepsQueryAndFragment(t *testing.T) {
+	files := transformedFiles(t, map[string]string{
+		"index.html": `<script src="app.js?v=1#main"></script>`,
+		"app.js":     `app()`,
+	}, config.Values{"FINGERPRINT": "true"})
+	want := `src="` + files["app.js"].TargetPath + `?v=1#main"`
+	if content := uploaded(t, files["index.html"]); !strings.Contains(content, want) {
+		t.Errorf("index.html = %s, want it to contain %s", content, want)
+	}
+}
+
+func TestFingerprintKeepsMinifiedPages(t *testing.T) {
+	files := transformedFiles(t, map[string]string{
+		"index.html": "<p>\n  no   references\n</p>\n",
+		"app.html":   "<script src=\"app.js\"></script>\n\n<p>  app  </p>",
+		"app.js":     "app( 1 )",
+	}, config.Values{"MINIFY": "true", "FINGERPRINT": "true"})
+
+	if got, want := uploaded(t, files["index.html"]), "<p> no references </p>"; got != want {
+		t.Errorf("index.html = %q, want %q", got, want)
+	}
+	if got, want := uploaded(t, files["app.html"]), `<script src="`+files["app.js"].TargetPath+`"></script> <p> app </p>`; got != want {
+		t.Errorf("app.html = %q, want %q", got, want)
+	}
+	if got := uploaded(t, files["app.js"]); got != "app(1)" {
+		t.Errorf("app.js = %q, want it minified", got)
+	}
+}
+
+// The manifest records the digest of the content uploaded, so deploying the
+// same site again uploads nothing.
+func TestFingerprintManifest(t *testing.T) {
+	cfg := testConfig(t, config.Values{
+		"FOLDER": writeFolder(t, map[string]string{
+			"index.html": "<link rel=\"stylesheet\" href=\"style.css\">\n<p>  home  </p>",
+			"about.html": "<p>  about  </p>",
+			"style.css":  "body { background: url(bg.png) }",
+			"bg.png":     "bg",
+		}),
+		"MINIFY":      "true",
+		"FINGERPRINT": "true",
+	})
+	backend := newMemoryBackend()
+	if err := deploy(backend, cfg, newDeployStats("first", cfg.Bucket)); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(backend.objects["about.html"].body); got != "<p> about </p>" {
+		t.Errorf("about.html is uploaded as %q, want it minified", got)
+	}
+
+	stats := newDeployStats("second", cfg.Bucket)
+	if err := deploy(backend, cfg, stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Uploaded != 0 || stats.Deleted != 0 {
+		t.Errorf("second deploy uploaded %d and deleted %d files, want none: %+v", stats.Uploaded, stats.Deleted, stats.Files)
+	}
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+	"github.com/rizaldntr/storage-service-website-action/utils"
+)
+
+// hashCacheVersion is bumped whenever the on-disk format changes, older
+// cache files are then discarded.
+const hashCacheVersion = 1
+
+type hashCacheEntry struct {
+	Size    int64
+	ModTime int64
+	Inode   uint64
+	Digests types.Digests
+}
+
+// HashCache remembers the digests of files between runs, keyed by source
+// path. An entry is only used while the size, modification time and inode
+// of the file are unchanged. A nil *HashCache caches nothing.
+type HashCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]hashCacheEntry
+	seen    map[string]hashCacheEntry
+}
+
+type hashCacheFile struct {
+	Version int
+	Entries map[string]hashCacheEntry
+}
+
+// LoadHashCache reads the cache stored at path. A missing or unreadable
+// cache yields an empty one; an empty path disables caching.
+func LoadHashCache(path string) *HashCache {
+	if path == "" {
+		return nil
+	}
+
+	cache := &HashCache{
+		path:    path,
+		entries: make(map[string]hashCacheEntry),
+		seen:    make(map[string]hashCacheEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Warningf("Unable to read hash cache %s: %v", path, err)
+		}
+		return cache
+	}
+
+	var file hashCacheFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != hashCacheVersion {
+		logger.Warningf("Discarding incompatible hash cache %s", path)
+		return cache
+	}
+	if file.Entries != nil {
+		cache.entries = file.Entries
+	}
+	logger.Infof("Loaded %d entries from hash cache %s", len(cache.entries), path)
+	return cache
+}
+
+// Hash returns the digests of the file at path, reusing the cached ones
+// when the file is unchanged and all algorithms are present.
+func (c *HashCache) Hash(path string, algorithms ...types.HashAlgorithm) (types.Digests, error) {
+	if c == nil {
+		return utils.HashFile(path, algorithms...)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return types.Digests{}, err
+	}
+	key := hashCacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Inode:   utils.Inode(info),
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.Size == key.Size && entry.ModTime == key.ModTime &&
+		entry.Inode == key.Inode && entry.Digests.Has(algorithms...) {
+		c.mu.Lock()
+		c.seen[path] = entry
+		c.mu.Unlock()
+		return entry.Digests, nil
+	}
+
+	digests, err := utils.HashFile(path, algorithms...)
+	if err != nil {
+		return types.Digests{}, err
+	}
+	key.Digests = digests
+
+	c.mu.Lock()
+	c.seen[path] = key
+	c.mu.Unlock()
+	return digests, nil
+}
+
+// Save writes the entries used during this run, dropping files that no
+// longer exist so the cache does not grow forever.
+func (c *HashCache) Save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	data, err := json.Marshal(hashCacheFile{Version: hashCacheVersion, Entries: c.seen})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o644)
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// DeploysPrefix is where a copy of the manifest of every deploy is kept, so
+// that a deploy can be rolled back to on a versioned bucket.
+const DeploysPrefix = ".deploys/"
+
+type Deploy struct {
+	ID   string
+	Time time.Time
+}
+
+// NewDeployID returns a sortable identifier for a deploy started at now.
+func NewDeployID(now time.Time) string {
+	return now.UTC().Format("20060102T150405.000Z")
+}
+
+// isInternalKey reports whether key is written by the action itself rather
+// than deployed from the folder.
+func isInternalKey(key string) bool {
+	return key == IncrementalConfig || strings.HasPrefix(key, DeploysPrefix)
+}
+
+// managedObjects returns the objects of the deployed prefix, leaving out
+// the internal keys and the objects of other deploys under it: the
+// previews, and every prefix holding its own manifest or deploy history,
+// such as an environment or a mount deployed by another pipeline.
+func managedObjects(objects []types.ObjectInfo, previewPrefix string) []types.ObjectInfo {
+	others := []string{}
+	if previewPrefix != "" {
+		others = append(others, previewPrefix)
+	}
+	for _, obj := range objects {
+		if prefix, ok := strings.CutSuffix(obj.Key, "/"+IncrementalConfig); ok {
+			others = append(others, prefix+"/")
+		} else if i := strings.Index(obj.Key, "/"+DeploysPrefix); i >= 0 {
+			others = append(others, obj.Key[:i+1])
+		}
+	}
+
+	managed := make([]types.ObjectInfo, 0, len(objects))
+	for _, obj := range objects {
+		if isInternalKey(obj.Key) || slices.ContainsFunc(others, func(prefix string) bool {
+			return strings.HasPrefix(obj.Key, prefix)
+		}) {
+			continue
+		}
+		managed = append(managed, obj)
+	}
+	return managed
+}
+
+func deployKey(id string) string {
+	return DeploysPrefix + id + ".json"
+}
+
+// saveManifest stores manifest as the current .incremental and as the
+// history entry of deploy id.
+func saveManifest(backend Backend, manifest *types.IncrementalConfig, id string) error {
+	data, err := manifest.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("Error during .incremental marshalling: %v", err)
+	}
+
+	for _, key := range []string{IncrementalConfig, deployKey(id)} {
+		err := backend.PutObject(types.PutObjectRequest{
+			ACL:         types.PrivateACL,
+			Body:        bytes.NewReader(data),
+			ContentType: "application/json",
+			Key:         key,
+		})
+		if err != nil {
+			return fmt.Errorf("Error while saving %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// listDeploys returns the recorded deploys, oldest first.
+func listDeploys(backend Backend) ([]Deploy, error) {
+	objects, err := backend.ListObjects(DeploysPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	deploys := make([]Deploy, 0, len(objects))
+	for _, obj := range objects {
+		id := strings.TrimSuffix(strings.TrimPrefix(obj.Key, DeploysPrefix), ".json")
+		deploys = append(deploys, Deploy{ID: id, Time: obj.LastModified})
+	}
+	sort.Slice(deploys, func(a, b int) bool {
+		return deploys[a].ID < deploys[b].ID
+	})
+	return deploys, nil
+}
+
+func fetchDeployManifest(backend Backend, id string) (*types.IncrementalConfig, error) {
+	data, err := backend.GetObject(deployKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to fetch deploy %s: %v", id, err)
+	}
+
+	manifest := types.NewIncrementalConfig()
+	if err := manifest.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("Unable to read deploy %s: %v", id, err)
+	}
+	return manifest, nil
+}
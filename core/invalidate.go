@@ -0,0 +1,137 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rizaldntr/storage-service-website-action/cdn"
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+)
+
+// CDN invalidates cached paths. Paths start with a slash and are URL
+// encoded, a trailing * matches every path below.
+type CDN interface {
+	// Invalidate submits an invalidation of paths and returns its id. The
+	// reference identifies the request to providers deduplicating them.
+	Invalidate(reference string, paths []string) (string, error)
+	// Wait blocks until invalidation id has completed.
+	Wait(id string) error
+}
+
+func newCDN(cdnConfig config.CDNConfig) (CDN, error) {
+	switch cdnConfig.Provider {
+	case config.CloudFront:
+		return cdn.NewCloudFront(cdnConfig)
+	case config.Cloudflare:
+		return cdn.NewCloudflare(cdnConfig), nil
+	case config.Fastly:
+		return cdn.NewFastly(cdnConfig), nil
+	}
+	return nil, fmt.Errorf("Unknown CDN %s", cdnConfig.Provider)
+}
+
+// invalidate purges the paths of the keys changed by the deploy from the
+// CDN of config. Nothing is sent when no key changed.
+func invalidate(config config.Config, stats *DeployStats) (string, error) {
+	keys := stats.ChangedPaths()
+	if len(keys) == 0 {
+		logger.Infof("No changed paths to invalidate")
+		return "", nil
+	}
+
+	client, err := newCDN(config.CDN)
+	if err != nil {
+		return "", err
+	}
+
+	paths := invalidationPaths(keys, config.Prefix, config.FileConfig.IndexDocument)
+	if len(paths) > config.CDN.WildcardThreshold {
+		collapsed := collapsePaths(paths, config.CDN.WildcardThreshold, strings.Count(config.Prefix, "/"))
+		logger.Infof("Collapsed %d paths to %d wildcards", len(paths), len(collapsed))
+		paths = collapsed
+	}
+	for _, p := range paths {
+		logger.Debugf("Invalidating %s", p)
+	}
+
+	id, err := client.Invalidate(stats.DeployID, paths)
+	if err != nil {
+		return "", err
+	}
+	logger.Infof("Submitted %s invalidation %s of %d paths", config.CDN.Provider, id, len(paths))
+
+	if config.CDN.Wait {
+		logger.Infof("Waiting for invalidation %s to complete", id)
+		if err := client.Wait(id); err != nil {
+			return id, err
+		}
+		logger.Infof("Invalidation %s completed", id)
+	}
+	return id, nil
+}
+
+// invalidationPaths maps keys to the URL paths serving them. Index documents
+// kept under their name are also served for their directory.
+func invalidationPaths(keys []string, prefix, indexDocument string) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	add := func(key string) {
+		segments := strings.Split(prefix+key, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		p := "/" + strings.Join(segments, "/")
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+
+	for _, key := range keys {
+		add(key)
+		if name := path.Base(key); name == indexDocument {
+			add(strings.TrimSuffix(key, name))
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// collapsePaths replaces paths by wildcards of their directories, starting
+// with the deepest, until no more than threshold remain. Directories above
+// minDepth, the key prefix, are never collapsed into.
+func collapsePaths(paths []string, threshold, minDepth int) []string {
+	if len(paths) <= threshold {
+		return paths
+	}
+	maxDepth := 0
+	for _, p := range paths {
+		maxDepth = max(maxDepth, strings.Count(p, "/")-1)
+	}
+
+	collapsed := paths
+	for depth := maxDepth; depth >= minDepth; depth-- {
+		seen := make(map[string]bool)
+		collapsed = nil
+		for _, p := range paths {
+			dirs := strings.Split(p[1:], "/")
+			dirs = dirs[:len(dirs)-1]
+			if len(dirs) >= depth {
+				p = "/" + strings.Join(append(dirs[:depth:depth], "*"), "/")
+			}
+			if !seen[p] {
+				seen[p] = true
+				collapsed = append(collapsed, p)
+			}
+		}
+		if len(collapsed) <= threshold {
+			break
+		}
+	}
+	sort.Strings(collapsed)
+	return collapsed
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInvalidationPaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []string
+		prefix string
+		want   []string
+	}{
+		{
+			name: "index documents",
+			keys: []string{"index.html", "docs/index.html", "docs/intro.html"},
+			want: []string{"/", "/docs/", "/docs/index.html", "/docs/intro.html", "/index.html"},
+		},
+		{
+			name: "escaping",
+			keys: []string{"a b.html", "ü/#1.html"},
+			want: []string{"/%C3%BC/%231.html", "/a%20b.html"},
+		},
+		{
+			name:   "prefix",
+			keys:   []string{"index.html", "a.css"},
+			prefix: "site/",
+			want:   []string{"/site/", "/site/a.css", "/site/index.html"},
+		},
+		{
+			name: "duplicates",
+			keys: []string{"docs/", "docs/index.html"},
+			want: []string{"/docs/", "/docs/index.html"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := invalidationPaths(tt.keys, tt.prefix, "index.html")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalidationPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollapsePaths(t *testing.T) {
+	paths := []string{"/a/b/c.html", "/a/b/d.html", "/a/e.html", "/f.html"}
+	prefixed := []string{"/site/a/x.html", "/site/a/y.html", "/site/b/z.html", "/site/z.html"}
+	tests := []struct {
+		name      string
+		paths     []string
+		threshold int
+		minDepth  int
+		want      []string
+	}{
+		{"under threshold", paths, 4, 0, paths},
+		{"deepest first", paths, 3, 0, []string{"/a/b/*", "/a/e.html", "/f.html"}},
+		{"parent directories", paths, 2, 0, []string{"/a/*", "/f.html"}},
+		{"everything", paths, 1, 0, []string{"/*"}},
+		{"prefix", prefixed, 3, 1, []string{"/site/a/*", "/site/b/*", "/site/z.html"}},
+		{"never above the prefix", prefixed, 0, 1, []string{"/site/*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collapsePaths(tt.paths, tt.threshold, tt.minDepth)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collapsePaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/IGLOU-EU/go-wildcard/v2"
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/minify"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+func init() {
+	RegisterTransformer("minify", func(cfg config.Config) (Transformer, error) {
+		return minifyTransformer{patterns: cfg.Minify.Patterns}, nil
+	})
+}
+
+// minifyTransformer is the minify transform. The manifest records the
+// digest of its output, so a file is uploaded again when the minifier
+// changes it differently.
+type minifyTransformer struct {
+	patterns []string
+}
+
+// Applies matches the patterns against the key of file and the path of its
+// source in the folder, pages uploaded without their extension still
+// being minified like their other keys.
+func (t minifyTransformer) Applies(file types.FileInfo) bool {
+	if file.RedirectLocation != "" || !minify.Supports(file.Name) {
+		return false
+	}
+	source := ""
+	if file.SourcePath != "" {
+		source = filepath.ToSlash(strings.TrimPrefix(file.SourcePath, file.Dir))
+	}
+	for _, pattern := range t.patterns {
+		if wildcard.Match(pattern, file.TargetPath) || (source != "" && wildcard.Match(pattern, source)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Transform keeps a file that cannot be minified, e.g. invalid JSON, as it
+// is with a warning.
+func (t minifyTransformer) Transform(file types.FileInfo, content []byte) ([]types.FileInfo, error) {
+	minified, err := minify.Minify(file.Name, content)
+	if err != nil {
+		logger.Warningf("Not minifying %s: %v", file.TargetPath, err)
+		return []types.FileInfo{file}, nil
+	}
+	if len(minified) < len(content) {
+		file.Content = minified
+		file.MinifiedBytes += int64(len(content) - len(minified))
+	}
+	return []types.FileInfo{file}, nil
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+)
+
+func TestMinifyExtensionlessKeys(t *testing.T) {
+	site := map[string]string{
+		"index.html":      "<p>  home  </p>",
+		"about.html":      "<p>  about  </p>",
+		"docs/index.html": "<p>  docs  </p>",
+		"css/site.css":    "a {  color: red;  }",
+	}
+	tests := []struct {
+		name   string
+		values config.Values
+		want   map[string]string
+	}{
+		{
+			name:   "extension removed",
+			values: config.Values{"REMOVE_HTML_EXTENSION": "true", "INDEX_MODE": "bare"},
+			want:   map[string]string{"index.html": "<p> home </p>", "about": "<p> about </p>", "docs": "<p> docs </p>", "css/site.css": "a{color:red}"},
+		},
+		{
+			name:   "duplicated without extension",
+			values: config.Values{"DUPLICATE_HTML_WITH_NO_EXTENSION": "true", "INDEX_MODE": "slash"},
+			want: map[string]string{
+				"index.html": "<p> home </p>", "about": "<p> about </p>", "about.html": "<p> about </p>",
+				"docs/": "<p> docs </p>", "docs/index.html": "<p> docs </p>", "css/site.css": "a{color:red}",
+			},
+		},
+		{
+			name:   "patterns",
+			values: config.Values{"REMOVE_HTML_EXTENSION": "true", "MINIFY_PATTERNS": "docs/*.html\ncss/*"},
+			want:   map[string]string{"index.html": "<p>  home  </p>", "about": "<p>  about  </p>", "docs/index.html": "<p> docs </p>", "css/site.css": "a{color:red}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.values["FOLDER"] = writeFolder(t, site)
+			tt.values["MINIFY"] = "true"
+			files, _, err := ListFiles(testConfig(t, tt.values))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != len(tt.want) {
+				t.Errorf("keys = %v, want %d keys", fileKeys(files), len(tt.want))
+			}
+			for _, file := range files {
+				want, ok := tt.want[file.TargetPath]
+				if !ok {
+					t.Errorf("unexpected key %s", file.TargetPath)
+					continue
+				}
+				if got := uploaded(t, file); got != want {
+					t.Errorf("%s = %q, want %q", file.TargetPath, got, want)
+				}
+			}
+		})
+	}
+}
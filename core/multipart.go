@@ -0,0 +1,153 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+	"github.com/rizaldntr/storage-service-website-action/utils"
+)
+
+// maxMultipartParts is the maximum number of parts S3 accepts for a single
+// multipart upload.
+const maxMultipartParts = 10000
+
+// uploadMultipart uploads file in parts of config.ChunkSize bytes, sending up
+// to config.Concurrency parts at once. The upload is aborted on the first
+// failed part so no incomplete upload is left behind in the bucket.
+func uploadMultipart(backend Backend, file types.FileInfo, config config.MultipartConfig) error {
+	f, err := os.Open(file.SourcePath)
+	if err != nil {
+		return fmt.Errorf("Error opening file %s: %v", file.SourcePath, err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("Error reading file %s: %v", file.SourcePath, err)
+	}
+	size := stat.Size()
+	chunkSize := multipartChunkSize(size, config.ChunkSize)
+
+	objectKey := file.TargetPath
+	uploadID, err := backend.CreateMultipartUpload(types.PutObjectRequest{
+		ACL:               file.ACL,
+		CacheControl:      file.CacheControl,
+		ContentType:       file.ContentType,
+		Key:               objectKey,
+		ChecksumAlgorithm: file.ChecksumAlgorithm,
+	})
+	if err != nil {
+		return fmt.Errorf("Error creating multipart upload for %s: %v", objectKey, err)
+	}
+
+	parts, err := uploadParts(backend, f, size, chunkSize, objectKey, uploadID, file.ChecksumAlgorithm, config.Concurrency)
+	if err == nil {
+		err = backend.CompleteMultipartUpload(objectKey, uploadID, file.ChecksumAlgorithm, parts)
+	}
+	if err != nil {
+		if abortErr := backend.AbortMultipartUpload(objectKey, uploadID); abortErr != nil {
+			logger.Warningf("Unable to abort multipart upload of %s: %v", objectKey, abortErr)
+		}
+		return fmt.Errorf("Error uploading file %s in parts: %v", objectKey, err)
+	}
+
+	logger.Debugf("Uploaded %s in %d parts", objectKey, len(parts))
+	return nil
+}
+
+func uploadParts(backend Backend, f io.ReaderAt, size, chunkSize int64, key, uploadID string, checksum types.HashAlgorithm, concurrency int) ([]types.CompletedPart, error) {
+	count := partCount(size, chunkSize)
+	parts := make([]types.CompletedPart, 0, count)
+
+	var sw sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	sema := make(chan struct{}, concurrency)
+
+	for i := 0; i < count; i++ {
+		offset := int64(i) * chunkSize
+		length := min(chunkSize, size-offset)
+
+		sema <- struct{}{}
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			<-sema
+			break
+		}
+
+		sw.Add(1)
+		go func(partNumber int32, offset, length int64) {
+			defer sw.Done()
+			defer func() { <-sema }()
+
+			part, err := uploadPart(backend, io.NewSectionReader(f, offset, length), types.UploadPartRequest{
+				Key:               key,
+				UploadID:          uploadID,
+				PartNumber:        partNumber,
+				Size:              length,
+				ChecksumAlgorithm: checksum,
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("part %d: %v", partNumber, err)
+				}
+				return
+			}
+			parts = append(parts, part)
+		}(int32(i+1), offset, length)
+	}
+	sw.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Slice(parts, func(a, b int) bool {
+		return parts[a].PartNumber < parts[b].PartNumber
+	})
+	return parts, nil
+}
+
+// uploadPart digests the part before sending it, so the backend can verify
+// every part independently.
+func uploadPart(backend Backend, body *io.SectionReader, request types.UploadPartRequest) (types.CompletedPart, error) {
+	algorithms := []types.HashAlgorithm{types.MD5}
+	if request.ChecksumAlgorithm != "" {
+		algorithms = append(algorithms, request.ChecksumAlgorithm)
+	}
+	digests, err := utils.HashReader(body, algorithms...)
+	if err != nil {
+		return types.CompletedPart{}, err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return types.CompletedPart{}, err
+	}
+
+	request.Body = body
+	request.ContentMD5 = digests.MD5
+	request.Checksum = digests.Get(request.ChecksumAlgorithm)
+	return backend.UploadPart(request)
+}
+
+// multipartChunkSize grows chunkSize to a whole MiB when a file would
+// otherwise exceed the maximum number of parts.
+func multipartChunkSize(size, chunkSize int64) int64 {
+	if partCount(size, chunkSize) <= maxMultipartParts {
+		return chunkSize
+	}
+
+	const mib = 1 << 20
+	chunkSize = (size + maxMultipartParts - 1) / maxMultipartParts
+	return (chunkSize + mib - 1) / mib * mib
+}
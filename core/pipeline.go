@@ -0,0 +1,68 @@
+package core
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+	"github.com/rizaldntr/storage-service-website-action/utils"
+)
+
+// stage starts workers goroutines applying fn to every item of in and
+// returns a channel that is closed once all of them are done. The channel is
+// buffered to the worker count, so a slow stage applies backpressure to the
+// ones before it instead of piling up goroutines.
+func stage[In, Out any](in <-chan In, workers int, fn func(In, chan<- Out)) <-chan Out {
+	workers = max(workers, 1)
+	out := make(chan Out, workers)
+	var sw sync.WaitGroup
+	sw.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer sw.Done()
+			for item := range in {
+				fn(item, out)
+			}
+		}()
+	}
+	go func() {
+		sw.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// HashFiles computes the MD5 and, when checksum is set, the checksum of
+// every walked file in a single read, reusing the digests stored in cache
+// for unchanged files. Each file is opened and closed by a single worker
+// before the next one is read.
+func HashFiles(files <-chan types.FileInfo, workers int, cache *HashCache, checksum types.HashAlgorithm) <-chan types.FileInfo {
+	algorithms := []types.HashAlgorithm{types.MD5}
+	if checksum != "" {
+		algorithms = append(algorithms, checksum)
+	}
+
+	return stage(files, workers, func(file types.FileInfo, out chan<- types.FileInfo) {
+		var digests types.Digests
+		var err error
+		switch {
+		case file.RedirectLocation != "":
+			// redirects are uploaded without a body
+			digests, err = utils.HashReader(bytes.NewReader(nil), algorithms...)
+		case file.Content != nil:
+			digests, err = utils.HashReader(bytes.NewReader(file.Content), algorithms...)
+		default:
+			digests, err = cache.Hash(file.SourcePath, algorithms...)
+		}
+		if err != nil {
+			logger.Debugf("Failed to compute MD5 hash for file: %v", err)
+		}
+		file.ContentMD5 = digests.MD5
+		if checksum != "" {
+			file.ChecksumAlgorithm = checksum
+			file.Checksum = digests.Get(checksum)
+		}
+		out <- file
+	})
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+type PlannedUpload struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// Plan describes what a deploy of the same configuration would do.
+type Plan struct {
+	Bucket string `json:"bucket"`
+	// EmptyBucket is set when no manifest exists yet and the first deploy
+	// would empty the bucket before uploading.
+	EmptyBucket bool            `json:"empty_bucket"`
+	Uploads     []PlannedUpload `json:"uploads"`
+	Deletes     []string        `json:"deletes"`
+	Unchanged   int             `json:"unchanged"`
+	// BucketChanges are the bucket settings that would be updated.
+	BucketChanges []BucketChange `json:"bucket_changes"`
+	// Collisions are the keys written by more than one file.
+	Collisions []Collision `json:"collisions"`
+}
+
+// CreatePlan compares the folder with the bucket without writing anything.
+func CreatePlan(config config.Config) (*Plan, error) {
+	backend, err := newBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collisions the policy cannot resolve are listed rather than failing
+	// the plan.
+	localFiles, collisions, err := ListFiles(config)
+	if err != nil && !errors.Is(err, ErrKeyCollision) {
+		return nil, err
+	}
+
+	incremental, err := fetchState(backend, config.SyncMode, config.Preview.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Bucket:      config.Bucket,
+		EmptyBucket: !isRemoteSync(config.SyncMode) && incremental.Size() == 0,
+		Uploads:     []PlannedUpload{},
+		Deletes:     []string{},
+		Collisions:  []Collision{},
+	}
+	plan.Collisions = append(plan.Collisions, collisions...)
+
+	logger.Group("Comparing files")
+	files := HashFiles(emit(localFiles), config.Concurrency.Hash, LoadHashCache(config.HashCache), config.ChecksumAlgorithm)
+	for file := range files {
+		skip, reason := shouldSkip(file, incremental, config.Multipart.ChunkSize)
+		if skip {
+			plan.Unchanged++
+			continue
+		}
+		plan.Uploads = append(plan.Uploads, PlannedUpload{
+			Key:    file.TargetPath,
+			Source: file.SourcePath,
+			Reason: reason,
+		})
+	}
+	logger.EndGroup()
+
+	for key := range incremental.M {
+		plan.Deletes = append(plan.Deletes, key)
+	}
+
+	sort.Slice(plan.Uploads, func(a, b int) bool {
+		return plan.Uploads[a].Key < plan.Uploads[b].Key
+	})
+	sort.Strings(plan.Deletes)
+
+	plan.BucketChanges, err = PlanBucket(config)
+	if err != nil {
+		return nil, err
+	}
+	if plan.BucketChanges == nil {
+		plan.BucketChanges = []BucketChange{}
+	}
+	return plan, nil
+}
+
+// Unresolved reports whether the plan has collisions a deploy would fail on.
+func (p *Plan) Unresolved() bool {
+	for _, collision := range p.Collisions {
+		if collision.Winner == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchManifest returns the .incremental manifest currently in the bucket.
+func FetchManifest(config config.Config) (*types.IncrementalConfig, error) {
+	backend, err := newBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := backend.GetObject(IncrementalConfig)
+	if err != nil {
+		return nil, err
+	}
+	manifest := types.NewIncrementalConfig()
+	if err := manifest.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ListObjects returns the objects of the bucket under prefix.
+func ListObjects(config config.Config, prefix string) ([]types.ObjectInfo, error) {
+	backend, err := newBackend(config)
+	if err != nil {
+		return nil, err
+	}
+	return backend.ListObjects(prefix)
+}
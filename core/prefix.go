@@ -0,0 +1,119 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// prefixedBackend places every key under a prefix of the bucket, so the rest
+// of core works with keys relative to the deployed site.
+type prefixedBackend struct {
+	backend Backend
+	prefix  string
+}
+
+// withPrefix wraps backend so keys live under prefix, or returns it
+// unchanged when prefix is empty.
+func withPrefix(backend Backend, prefix string) Backend {
+	if prefix == "" {
+		return backend
+	}
+	return &prefixedBackend{backend: backend, prefix: prefix}
+}
+
+func (p *prefixedBackend) GetObject(key string) ([]byte, error) {
+	return p.backend.GetObject(p.prefix + key)
+}
+
+func (p *prefixedBackend) HeadObject(key string) (types.ObjectInfo, error) {
+	info, err := p.backend.HeadObject(p.prefix + key)
+	info.Key = strings.TrimPrefix(info.Key, p.prefix)
+	return info, err
+}
+
+func (p *prefixedBackend) ListObjects(prefix string) ([]types.ObjectInfo, error) {
+	objects, err := p.backend.ListObjects(p.prefix + prefix)
+	for i := range objects {
+		objects[i].Key = strings.TrimPrefix(objects[i].Key, p.prefix)
+	}
+	return objects, err
+}
+
+func (p *prefixedBackend) ListObjectVersions(prefix string) ([]types.ObjectVersion, error) {
+	versions, err := p.backend.ListObjectVersions(p.prefix + prefix)
+	for i := range versions {
+		versions[i].Key = strings.TrimPrefix(versions[i].Key, p.prefix)
+	}
+	return versions, err
+}
+
+func (p *prefixedBackend) CopyObject(request types.CopyObjectRequest) error {
+	request.Key = p.prefix + request.Key
+	return p.backend.CopyObject(request)
+}
+
+func (p *prefixedBackend) PutObject(request types.PutObjectRequest) error {
+	request.Key = p.prefix + request.Key
+	if strings.HasPrefix(request.WebsiteRedirectLocation, "/") {
+		request.WebsiteRedirectLocation = "/" + p.prefix + request.WebsiteRedirectLocation[1:]
+	}
+	return p.backend.PutObject(request)
+}
+
+func (p *prefixedBackend) CreateMultipartUpload(request types.PutObjectRequest) (string, error) {
+	request.Key = p.prefix + request.Key
+	return p.backend.CreateMultipartUpload(request)
+}
+
+func (p *prefixedBackend) UploadPart(request types.UploadPartRequest) (types.CompletedPart, error) {
+	request.Key = p.prefix + request.Key
+	return p.backend.UploadPart(request)
+}
+
+func (p *prefixedBackend) CompleteMultipartUpload(key, uploadID string, algorithm types.HashAlgorithm, parts []types.CompletedPart) error {
+	return p.backend.CompleteMultipartUpload(p.prefix+key, uploadID, algorithm, parts)
+}
+
+func (p *prefixedBackend) AbortMultipartUpload(key, uploadID string) error {
+	return p.backend.AbortMultipartUpload(p.prefix+key, uploadID)
+}
+
+func (p *prefixedBackend) DeleteObject(key string) error {
+	return p.backend.DeleteObject(p.prefix + key)
+}
+
+func (p *prefixedBackend) DeleteObjects(keys []string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = p.prefix + key
+	}
+	return p.backend.DeleteObjects(prefixed)
+}
+
+// EmptyBucket only removes the objects under the prefix, the rest of the
+// bucket may belong to other environments.
+func (p *prefixedBackend) EmptyBucket() error {
+	objects, err := p.backend.ListObjects(p.prefix)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	return deleteKeys(p.backend, keys)
+}
+
+// deleteKeys deletes keys in as few DeleteObjects calls as possible.
+func deleteKeys(backend Backend, keys []string) error {
+	for len(keys) > 0 {
+		n := min(len(keys), deleteBatchSize)
+		if err := backend.DeleteObjects(keys[:n]); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
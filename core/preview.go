@@ -0,0 +1,142 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/utils"
+)
+
+// Preview is the deployment of a pull request under its own prefix.
+type Preview struct {
+	Number int `json:"number"`
+	// Prefix is the key prefix of the preview in the bucket.
+	Prefix string `json:"prefix"`
+	// URL is only known when a base URL is configured.
+	URL string `json:"url,omitempty"`
+}
+
+// newPreview resolves the preview of the pull request of config, or of the
+// one that triggered the workflow.
+func newPreview(config config.Config) (*Preview, error) {
+	number := config.Preview.Number
+	if number == 0 {
+		var err error
+		if number, err = utils.PullRequestNumber(); err != nil {
+			return nil, fmt.Errorf("Unable to determine the pull request number, set pr-number: %v", err)
+		}
+	}
+
+	preview := &Preview{
+		Number: number,
+		Prefix: config.Prefix + previewKey(config, number),
+	}
+	if config.Preview.BaseURL != "" {
+		preview.URL = strings.TrimSuffix(config.Preview.BaseURL, "/") + "/" + preview.Prefix
+	}
+	return preview, nil
+}
+
+// previewKey is the prefix of preview number relative to config.Prefix.
+func previewKey(config config.Config, number int) string {
+	return fmt.Sprintf("%spr-%d/", config.Preview.Prefix, number)
+}
+
+// DeployPreview deploys the folder under the prefix of the pull request and
+// sets the preview-url and preview-prefix outputs.
+func DeployPreview(config config.Config) (*Preview, error) {
+	preview, err := newPreview(config)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Deploying preview of pull request #%d to %s", preview.Number, preview.Prefix)
+	config.Prefix = preview.Prefix
+	if _, err := Process(config); err != nil {
+		return nil, err
+	}
+
+	logger.SetOutput("preview-prefix", preview.Prefix)
+	if preview.URL != "" {
+		logger.SetOutput("preview-url", preview.URL)
+		logger.Noticef("Preview of pull request #%d deployed to %s", preview.Number, preview.URL)
+	}
+	return preview, nil
+}
+
+// CleanupPreview deletes every object of the preview of the pull request,
+// including its manifest and deploy history.
+func CleanupPreview(config config.Config) (*Preview, error) {
+	preview, err := newPreview(config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Prefix = preview.Prefix
+	backend, err := newBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("Removing preview of pull request #%d from %s", preview.Number, preview.Prefix)
+	if err := backend.EmptyBucket(); err != nil {
+		return nil, fmt.Errorf("Error while removing preview %s: %v", preview.Prefix, err)
+	}
+	return preview, nil
+}
+
+// CollectPreviews deletes the previews whose latest object was written
+// before now minus the max age, and returns their prefixes.
+func CollectPreviews(config config.Config, now time.Time) ([]string, error) {
+	backend, err := newBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := backend.ListObjects(config.Preview.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	type previewObjects struct {
+		keys    []string
+		updated time.Time
+	}
+	previews := make(map[string]*previewObjects)
+	for _, obj := range objects {
+		name, _, ok := strings.Cut(strings.TrimPrefix(obj.Key, config.Preview.Prefix), "/")
+		if !ok || !strings.HasPrefix(name, "pr-") {
+			continue
+		}
+		p := previews[name]
+		if p == nil {
+			p = &previewObjects{}
+			previews[name] = p
+		}
+		p.keys = append(p.keys, obj.Key)
+		if obj.LastModified.After(p.updated) {
+			p.updated = obj.LastModified
+		}
+	}
+
+	cutoff := now.Add(-config.Preview.MaxAge)
+	var removed []string
+	for name, p := range previews {
+		if !p.updated.Before(cutoff) {
+			continue
+		}
+		prefix := config.Prefix + config.Preview.Prefix + name + "/"
+		logger.Infof("Removing preview %s, last deployed %s", prefix, p.updated.Format(time.RFC3339))
+		if err := deleteKeys(backend, p.keys); err != nil {
+			return removed, fmt.Errorf("Error while removing preview %s: %v", prefix, err)
+		}
+		removed = append(removed, prefix)
+	}
+	sort.Strings(removed)
+	logger.Infof("Removed %d of %d previews", len(removed), len(previews))
+	return removed, nil
+}
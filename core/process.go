@@ -3,212 +3,399 @@ package core
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
-	"sync"
-	"sync/atomic"
+	"time"
 
 	"github.com/rizaldntr/storage-service-website-action/backend"
 	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
 	"github.com/rizaldntr/storage-service-website-action/types"
-	"github.com/sethvargo/go-githubactions"
+	"github.com/rizaldntr/storage-service-website-action/utils"
 )
 
 const IncrementalConfig = ".incremental"
 
+// deleteBatchSize is the maximum number of keys of a DeleteObjects call.
+const deleteBatchSize = 1000
+
 type Backend interface {
 	GetObject(key string) ([]byte, error)
+	HeadObject(key string) (types.ObjectInfo, error)
+	ListObjects(prefix string) ([]types.ObjectInfo, error)
+	ListObjectVersions(prefix string) ([]types.ObjectVersion, error)
+	CopyObject(request types.CopyObjectRequest) error
 	PutObject(request types.PutObjectRequest) error
+	CreateMultipartUpload(request types.PutObjectRequest) (string, error)
+	UploadPart(request types.UploadPartRequest) (types.CompletedPart, error)
+	CompleteMultipartUpload(key, uploadID string, algorithm types.HashAlgorithm, parts []types.CompletedPart) error
+	AbortMultipartUpload(key, uploadID string) error
 	DeleteObject(key string) error
 	DeleteObjects(keys []string) error
 	EmptyBucket() error
 }
 
-func Process(config config.Config) error {
-	backend, err := backend.NewS3(config)
+// newBackend creates the storage backend for the prefix of config, with the
+// throttling limits of config applied to every call.
+func newBackend(config config.Config) (Backend, error) {
+	s3, err := backend.NewS3(config)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return throttle(withPrefix(s3, config.Prefix), config.Throttle), nil
+}
 
-	githubactions.Infof("Initiating incremental upload")
-	githubactions.Group("Fetching .fileinfo from backend storage")
-	ibytes, err := backend.GetObject(IncrementalConfig)
+// Process deploys the folder and invalidates the changed paths from the
+// CDN, then publishes the statistics of the deploy as step outputs and job
+// summary and writes the report files. The outputs, reports and webhooks
+// are given the result, failed or not.
+func Process(config config.Config) (*DeployStats, error) {
+	deployID := NewDeployID(time.Now())
+	logger.Infof("Starting deploy %s", deployID)
+	stats := newDeployStats(deployID, config.Bucket)
+
+	backend, err := newBackend(config)
+	if err == nil {
+		err = deploy(backend, config, stats)
+	}
+	finish(config, stats, err)
+	return stats, err
+}
+
+// finish records the result of the deploy, publishes it and sends it to
+// the webhooks.
+func finish(config config.Config, stats *DeployStats, deployErr error) {
+	stats.finish(deployErr)
+	stats.Publish()
+	stats.WriteReports(config.Report)
+	notify(config, stats)
+}
+
+func deploy(backend Backend, config config.Config, stats *DeployStats) error {
+	start := time.Now()
+	localFiles, _, err := ListFiles(config)
 	if err != nil {
-		githubactions.Warningf("Unable to retrieve .fileinfo: %v", err)
-		githubactions.Warningf("Proceeding to upload all files")
+		return err
 	}
+	stats.track("List files", start)
+	stats.recordMinified(localFiles)
 
-	incremental := types.NewIncrementalConfig()
-	err = incremental.UnmarshalJSON(ibytes)
+	if err := preDeploy(config, stats, len(localFiles)); err != nil {
+		return err
+	}
+
+	if configuresBucket(config.Website) {
+		logger.Group("Configuring bucket")
+		start = time.Now()
+		bucket, err := newBucketConfigurator(config)
+		if err == nil {
+			err = configureBucket(bucket, config)
+		}
+		logger.EndGroup()
+		if err != nil {
+			return err
+		}
+		stats.track("Configure bucket", start)
+	}
+
+	start = time.Now()
+	incremental, err := fetchState(backend, config.SyncMode, config.Preview.Prefix)
 	if err != nil {
-		githubactions.Warningf("Failed to unmarshal .fileinfo: %v", err)
+		return err
 	}
-	githubactions.EndGroup()
+	stats.track("Fetch remote state", start)
 
 	// Cleanup bucket for first run
-	if incremental.Size() == 0 {
-		githubactions.Group("Cleaning up bucket for first run")
-		githubactions.Infof("Starting cleanup process")
-		if err := backend.EmptyBucket(); err != nil {
-			githubactions.Warningf("Error during bucket cleanup: %v", err)
+	if !isRemoteSync(config.SyncMode) && incremental.Size() == 0 {
+		logger.Group("Cleaning up bucket for first run")
+		logger.Infof("Starting cleanup process")
+		if err := emptyPrefix(backend, config.Preview.Prefix); err != nil {
+			logger.Warningf("Error during bucket cleanup: %v", err)
 		}
-		githubactions.Infof("Cleanup process completed")
+		logger.Infof("Cleanup process completed")
+		logger.EndGroup()
 	}
-	githubactions.EndGroup()
 
-	githubactions.Group("Uploading files")
-	githubactions.Infof("Commencing file upload")
-	files := WalkDir(config)
-	uploaded, _ := upload(backend, files, incremental)
-	githubactions.Infof("File upload completed")
-	githubactions.EndGroup()
+	logger.Group("Uploading files")
+	logger.Infof("Commencing file upload")
+	start = time.Now()
+	cache := LoadHashCache(config.HashCache)
+	files := HashFiles(emit(localFiles), config.Concurrency.Hash, cache, config.ChecksumAlgorithm)
+	uploaded, _ := upload(backend, files, incremental, config, stats)
+	stats.track("Hash and upload", start)
+	logger.Infof("File upload completed")
+	if err := cache.Save(); err != nil {
+		logger.Warningf("Unable to save hash cache: %v", err)
+	}
+	logger.EndGroup()
 
 	if incremental.Size() > 0 {
-		githubactions.Group("Removing leftover files")
-		githubactions.Infof("Commencing removal of leftover files")
-		errs := delete(backend, incremental)
+		logger.Group("Removing leftover files")
+		logger.Infof("Commencing removal of leftover files")
+		start = time.Now()
+		errs := delete(backend, incremental, config.Concurrency.Delete, stats)
+		stats.track("Remove leftovers", start)
 		if len(errs) > 0 {
-			githubactions.Warningf("Error while removing leftover files: %v", errs)
+			logger.Warningf("Error while removing leftover files: %v", errs)
 		}
-		githubactions.Infof("Removal of leftover files completed")
-		githubactions.EndGroup()
+		logger.Infof("Removal of leftover files completed")
+		logger.EndGroup()
 	}
 
-	githubactions.Group("Saving incremental configuration")
-	githubactions.Infof("Generating incremental configuration")
+	logger.Group("Saving incremental configuration")
+	logger.Infof("Generating incremental configuration")
+	start = time.Now()
 	newIncremental := types.IncrementalConfigFromFileInfos(uploaded)
-	nbytes, err := newIncremental.MarshalJSON()
+	logger.Infof("Saving incremental configuration for deploy %s", stats.DeployID)
+	if err := saveManifest(backend, newIncremental, stats.DeployID); err != nil {
+		logger.Warningf("%v", err)
+	}
+	logger.Infof("Incremental configuration saving completed")
+	stats.track("Save manifest", start)
+	logger.EndGroup()
+
+	var cdnErr error
+	if config.CDN.Enabled() {
+		logger.Group("Invalidating CDN")
+		start = time.Now()
+		stats.InvalidationID, cdnErr = invalidate(config, stats)
+		stats.track("Invalidate CDN", start)
+		logger.EndGroup()
+	}
+
+	return cdnErr
+}
+
+func isRemoteSync(mode config.SyncMode) bool {
+	return mode == config.RemoteSync
+}
+
+// fetchState returns the remote state files are compared against, either
+// from the manifest or from the bucket listing depending on mode.
+func fetchState(backend Backend, mode config.SyncMode, previewPrefix string) (*types.IncrementalConfig, error) {
+	if isRemoteSync(mode) {
+		logger.Infof("Initiating sync against remote listing")
+		logger.Group("Listing objects from backend storage")
+		defer logger.EndGroup()
+		incremental, err := fetchRemoteState(backend, previewPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to list objects: %v", err)
+		}
+		logger.Infof("Found %d objects in backend storage", incremental.Size())
+		return incremental, nil
+	}
+
+	logger.Infof("Initiating incremental upload")
+	logger.Group("Fetching .fileinfo from backend storage")
+	defer logger.EndGroup()
+	return fetchIncremental(backend), nil
+}
+
+func fetchIncremental(backend Backend) *types.IncrementalConfig {
+	ibytes, err := backend.GetObject(IncrementalConfig)
 	if err != nil {
-		githubactions.Warningf("Error during .fileinfo marshalling: %v", err)
+		logger.Warningf("Unable to retrieve .fileinfo: %v", err)
+		logger.Warningf("Proceeding to upload all files")
 	}
-	githubactions.Infof("Saving incremental configuration")
-	err = backend.PutObject(types.PutObjectRequest{
-		ACL:  types.PrivateACL,
-		Body: bytes.NewReader(nbytes),
-		Key:  IncrementalConfig,
-	})
+
+	incremental := types.NewIncrementalConfig()
+	err = incremental.UnmarshalJSON(ibytes)
 	if err != nil {
-		githubactions.Warningf("Error while saving .fileinfo: %v", err)
+		logger.Warningf("Failed to unmarshal .fileinfo: %v", err)
 	}
-	githubactions.Infof("Incremental configuration saving completed")
-	githubactions.EndGroup()
+	return incremental
+}
+
+// fetchRemoteState builds the remote state from the bucket listing instead
+// of the manifest, so objects written by other tools are accounted for.
+func fetchRemoteState(backend Backend, previewPrefix string) (*types.IncrementalConfig, error) {
+	objects, err := backend.ListObjects("")
+	if err != nil {
+		return nil, err
+	}
+	return types.IncrementalConfigFromObjectInfos(managedObjects(objects, previewPrefix)), nil
+}
+
+// emptyPrefix deletes the objects of the deployed prefix before a first
+// deploy, leaving those of other deploys under it.
+func emptyPrefix(backend Backend, previewPrefix string) error {
+	objects, err := backend.ListObjects("")
+	if err != nil {
+		return err
+	}
+	objects = managedObjects(objects, previewPrefix)
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	return deleteKeys(backend, keys)
+}
+
+type classifiedFile struct {
+	file   types.FileInfo
+	skip   bool
+	reason string
+}
 
-	return nil
+type uploadResult struct {
+	file     types.FileInfo
+	reason   string
+	uploaded []types.FileInfo
+	skipped  bool
+	duration time.Duration
+	err      error
 }
 
-func upload(backend Backend, files <-chan types.FileInfo, i *types.IncrementalConfig) ([]types.FileInfo, []error) {
-	var sw sync.WaitGroup
-	var sema = make(chan struct{}, 30)
-	var errMutex sync.Mutex
-	var uplMutex sync.Mutex
+// upload classifies hashed files against the remote state and uploads the
+// changed ones. Both steps run as bounded stages and the results are
+// gathered by this goroutine alone.
+func upload(backend Backend, files <-chan types.FileInfo, i *types.IncrementalConfig, config config.Config, stats *DeployStats) ([]types.FileInfo, []error) {
 	var errs []error
-	var totalError atomic.Int64
-	var totalFile atomic.Int64
-	var totalSkipped atomic.Int64
-	var totalUploadedFiles atomic.Int64
+	var totalError, totalFile, totalSkipped, totalUploadedFiles int
 	uploaded := make([]types.FileInfo, 0, 100)
 
-	for file := range files {
-		sw.Add(1)
-		go func(file types.FileInfo) {
-			defer sw.Done()
-			objectKey := file.TargetPath
-			totalFile.Add(1)
-
-			if shouldSkip(file, i) {
-				uplMutex.Lock()
-				uploaded = append(uploaded, file)
-				uplMutex.Unlock()
-				totalSkipped.Add(1)
-				githubactions.Infof("Skipping upload of %s as the content is unchanged", objectKey)
-				return
-			}
+	multipart := config.Multipart
+	classified := stage(files, config.Concurrency.Hash, func(file types.FileInfo, out chan<- classifiedFile) {
+		skip, reason := shouldSkip(file, i, multipart.ChunkSize)
+		out <- classifiedFile{file: file, skip: skip, reason: reason}
+	})
+	results := stage(classified, config.Concurrency.Upload, func(c classifiedFile, out chan<- uploadResult) {
+		if c.skip {
+			out <- uploadResult{file: c.file, reason: c.reason, uploaded: []types.FileInfo{c.file}, skipped: true}
+			return
+		}
+		start := time.Now()
+		upl, err := handleUpload(backend, c.file, multipart)
+		out <- uploadResult{file: c.file, reason: c.reason, uploaded: upl, duration: time.Since(start), err: err}
+	})
 
-			sema <- struct{}{}
-			upl, err := handleUpload(backend, file)
-			<-sema
-			if err != nil {
-				errMutex.Lock()
-				errs = append(errs, err)
-				errMutex.Unlock()
-				totalError.Add(1)
-				githubactions.Errorf("Error while uploading %s: %v", objectKey, err)
-				return
+	for result := range results {
+		objectKey := result.file.TargetPath
+		totalFile++
+		report := FileResult{
+			Source:   result.file.SourcePath,
+			Key:      objectKey,
+			Reason:   result.reason,
+			Bytes:    result.file.Size,
+			Duration: result.duration,
+		}
+		switch {
+		case result.skipped:
+			totalSkipped++
+			logger.Infof("Skipping upload of %s as the content is unchanged", objectKey)
+			report.Action = ActionSkipped
+		case result.err != nil:
+			errs = append(errs, result.err)
+			totalError++
+			logger.Errorf("Error while uploading %s: %v", objectKey, result.err)
+			report.Action = ActionFailed
+			report.Error = result.err.Error()
+			stats.record(report)
+			continue
+		default:
+			totalUploadedFiles++
+			logger.Infof("Successfully uploaded %s", objectKey)
+			report.Action = ActionUploaded
+			if isMetadataChange(result.reason) {
+				report.Action = ActionMetadataUpdated
 			}
-
-			uplMutex.Lock()
-			uploaded = append(uploaded, upl...)
-			uplMutex.Unlock()
-			totalUploadedFiles.Add(1)
-			githubactions.Infof("Successfully uploaded %s", objectKey)
-		}(file)
+		}
+		stats.record(report)
+		uploaded = append(uploaded, result.uploaded...)
 	}
-	sw.Wait()
 
-	githubactions.Infof("Total Files: %d", totalFile.Load())
-	githubactions.Infof("Total Skipped Files: %d", totalSkipped.Load())
-	githubactions.Infof("Total Uploaded Files: %d", totalUploadedFiles.Load())
-	githubactions.Infof("Total Errors: %d", totalError.Load())
+	logger.Infof("Total Files: %d", totalFile)
+	logger.Infof("Total Skipped Files: %d", totalSkipped)
+	logger.Infof("Total Uploaded Files: %d", totalUploadedFiles)
+	logger.Infof("Total Errors: %d", totalError)
 
 	return uploaded, errs
 }
 
-func delete(backend Backend, i *types.IncrementalConfig) []error {
-	count := 0
-	maxKeys := 1000
-	keys := make([]string, 0, maxKeys)
-	deletedKeys := make([]string, 0, 20)
+func delete(backend Backend, i *types.IncrementalConfig, concurrency int, stats *DeployStats) []error {
+	batches := make(chan []string)
+	go func() {
+		defer close(batches)
+		keys := make([]string, 0, deleteBatchSize)
+		for k := range i.M {
+			keys = append(keys, k)
+			if len(keys) == deleteBatchSize {
+				batches <- keys
+				keys = make([]string, 0, deleteBatchSize)
+			}
+		}
+		if len(keys) > 0 {
+			batches <- keys
+		}
+	}()
+
+	type deleteResult struct {
+		keys     []string
+		duration time.Duration
+		err      error
+	}
+	results := stage(batches, concurrency, func(keys []string, out chan<- deleteResult) {
+		start := time.Now()
+		err := backend.DeleteObjects(keys)
+		out <- deleteResult{keys: keys, duration: time.Since(start), err: err}
+	})
 
 	var errs []error
-	var sw sync.WaitGroup
-	var errMutex sync.Mutex
-	var delMutex sync.Mutex
-
-	sema := make(chan struct{}, 10)
-	for k := range i.M {
-		keys = append(keys, k)
-		count++
-		if (count > 0 && count%maxKeys == 0) || count == len(i.M) {
-			sw.Add(1)
-			go func(keys []string) {
-				defer sw.Done()
-				sema <- struct{}{}
-				err := backend.DeleteObjects(keys)
-				<-sema
-				if err != nil {
-					errMutex.Lock()
-					errs = append(errs, err)
-					errMutex.Unlock()
-					githubactions.Errorf("Error while deleting objects: %v", err)
-				} else {
-					delMutex.Lock()
-					deletedKeys = append(deletedKeys, keys...)
-					delMutex.Unlock()
-				}
-			}(keys)
-			keys = make([]string, 0, maxKeys)
+	deletedKeys := make([]string, 0, 20)
+	for result := range results {
+		for _, key := range result.keys {
+			report := FileResult{Key: key, Action: ActionDeleted, Reason: "not in folder", Duration: result.duration}
+			if result.err != nil {
+				report.Action = ActionFailed
+				report.Error = result.err.Error()
+			}
+			stats.record(report)
 		}
+		if result.err != nil {
+			errs = append(errs, result.err)
+			logger.Errorf("Error while deleting objects: %v", result.err)
+			continue
+		}
+		deletedKeys = append(deletedKeys, result.keys...)
 	}
-	sw.Wait()
 	for _, key := range deletedKeys {
-		githubactions.Infof("Successfully deleted %s", key)
+		logger.Infof("Successfully deleted %s", key)
 	}
 	return errs
 }
 
-func handleUpload(backend Backend, file types.FileInfo) ([]types.FileInfo, error) {
-	body, err := os.Open(file.SourcePath)
-	if err != nil {
-		return nil, fmt.Errorf("Error opening file %s: %v", file.SourcePath, err)
+func handleUpload(backend Backend, file types.FileInfo, multipart config.MultipartConfig) ([]types.FileInfo, error) {
+	result := make([]types.FileInfo, 0, 2)
+	// rewritten content is already in memory
+	if file.Size >= multipart.Threshold && file.RedirectLocation == "" && file.Content == nil {
+		if err := uploadMultipart(backend, file, multipart); err != nil {
+			return nil, err
+		}
+		result = append(result, file)
+		return result, nil
+	}
+
+	var body io.Reader = bytes.NewReader(file.Content)
+	if file.RedirectLocation == "" && file.Content == nil {
+		f, err := os.Open(file.SourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening file %s: %v", file.SourcePath, err)
+		}
+		defer f.Close()
+		body = f
 	}
 
 	objectKey := file.TargetPath
-	result := make([]types.FileInfo, 0, 2)
-	err = backend.PutObject(types.PutObjectRequest{
-		ACL:          file.ACL,
-		Body:         body,
-		CacheControl: file.CacheControl,
-		ContentType:  file.ContentType,
-		Key:          objectKey,
+	err := backend.PutObject(types.PutObjectRequest{
+		ACL:                     file.ACL,
+		Body:                    body,
+		CacheControl:            file.CacheControl,
+		ContentType:             file.ContentType,
+		Key:                     objectKey,
+		ContentMD5:              file.ContentMD5,
+		ChecksumAlgorithm:       file.ChecksumAlgorithm,
+		Checksum:                file.Checksum,
+		WebsiteRedirectLocation: file.RedirectLocation,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("Error uploading file %s: %v", objectKey, err)
@@ -217,20 +404,102 @@ func handleUpload(backend Backend, file types.FileInfo) ([]types.FileInfo, error
 	return result, nil
 }
 
-func shouldSkip(item types.FileInfo, i *types.IncrementalConfig) bool {
+// isMetadataChange reports whether a file is uploaded only because of its
+// headers, as told by the reason given by shouldSkip.
+func isMetadataChange(reason string) bool {
+	return reason == "cache-control changed" || reason == "content-type changed" || reason == "acl changed"
+}
+
+// shouldSkip reports whether item is unchanged compared to the remote state,
+// and otherwise why it has to be uploaded.
+func shouldSkip(item types.FileInfo, i *types.IncrementalConfig, chunkSize int64) (bool, string) {
 	remoteConfig, ok := i.Get(item)
 	if !ok {
-		return false
+		return false, "new file"
 	}
 
 	// delete the item from the incremental config
 	// later we will delete leftover items from the incremental config
 	i.Delete(item)
 
-	if item.ContentMD5 != "" && item.ContentMD5 == remoteConfig.ContentMD5 &&
-		item.CacheControl == remoteConfig.CacheControl && item.ContentType == remoteConfig.ContentType {
-		return true
+	if remoteConfig.ContentMD5 == "" && remoteConfig.ETag != "" {
+		if matchesETag(item, remoteConfig, chunkSize) {
+			return true, "unchanged"
+		}
+		return false, "etag changed"
+	}
+
+	switch {
+	case item.ContentMD5 == "" || item.ContentMD5 != remoteConfig.ContentMD5:
+		return false, "md5 changed"
+	case item.CacheControl != remoteConfig.CacheControl:
+		return false, "cache-control changed"
+	case item.ContentType != remoteConfig.ContentType:
+		return false, "content-type changed"
+	// manifests written before ACLs were recorded have none
+	case item.ACL != remoteConfig.ACL && remoteConfig.ACL != "":
+		return false, "acl changed"
+	// a changed checksum algorithm re-uploads the object so that it is
+	// stored with the new checksum
+	case item.ChecksumAlgorithm != remoteConfig.ChecksumAlgorithm:
+		return false, "checksum algorithm changed"
+	case item.RedirectLocation != remoteConfig.RedirectLocation:
+		return false, "redirect changed"
+	}
+
+	return true, "unchanged"
+}
+
+// matchesETag compares a local file with a listed object. Multipart ETags
+// are recomputed locally using the part size implied by the remote ETag.
+func matchesETag(item types.FileInfo, remote types.IncrementalConfigValue, chunkSize int64) bool {
+	if item.Size != remote.Size || item.ContentMD5 == "" {
+		return false
+	}
+
+	if !utils.IsMultipartETag(remote.ETag) {
+		etag, err := utils.ETagFromMD5(item.ContentMD5)
+		return err == nil && etag == remote.ETag
+	}
+
+	if item.Content != nil {
+		return false
 	}
+	partSize := multipartPartSize(item.Size, utils.MultipartETagParts(remote.ETag), chunkSize)
+	if partSize == 0 {
+		return false
+	}
+	etag, err := utils.MultipartETag(item.SourcePath, partSize)
+	if err != nil {
+		logger.Debugf("Failed to compute multipart ETag for %s: %v", item.SourcePath, err)
+		return false
+	}
+	return etag == remote.ETag
+}
 
-	return false
+// multipartPartSize guesses the part size used to upload an object of size
+// bytes in parts. The configured chunk size is tried first, otherwise the
+// size is rounded up to a whole MiB as most uploaders do.
+func multipartPartSize(size int64, parts int, chunkSize int64) int64 {
+	if parts <= 0 {
+		return 0
+	}
+	if partCount(size, chunkSize) == parts {
+		return chunkSize
+	}
+
+	const mib = 1 << 20
+	partSize := (size + int64(parts) - 1) / int64(parts)
+	partSize = (partSize + mib - 1) / mib * mib
+	if partCount(size, partSize) != parts {
+		return 0
+	}
+	return partSize
+}
+
+func partCount(size, partSize int64) int {
+	if size == 0 {
+		return 1
+	}
+	return int((size + partSize - 1) / partSize)
 }
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+)
+
+// otherDeploys are the objects of deploys nested under the root of the
+// bucket: a preview and an environment with its own manifest.
+var otherDeploys = []string{
+	"previews/pr-1/index.html",
+	"previews/pr-1/.incremental",
+	"previews/pr-1/.deploys/20240101T000000.000Z.json",
+	"staging/index.html",
+	"staging/.incremental",
+}
+
+// writeFolder writes files, by path relative to a new folder, and returns
+// the folder.
+func writeFolder(t *testing.T, files map[string]string) string {
+	t.Helper()
+	folder := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(folder, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return folder
+}
+
+// testConfig loads values for bucket site, deploying a folder holding an
+// index.html unless a folder or mounts are given.
+func testConfig(t *testing.T, values config.Values) config.Config {
+	t.Helper()
+	if values["FOLDER"] == "" && values["MOUNTS"] == "" {
+		values["FOLDER"] = writeFolder(t, map[string]string{"index.html": "<p>root</p>"})
+	}
+	values["BUCKET"] = "site"
+	cfg, err := config.Load(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestRootDeployKeepsOtherDeploys(t *testing.T) {
+	for _, mode := range []string{"manifest", "remote"} {
+		t.Run(mode, func(t *testing.T) {
+			backend := newMemoryBackend(append([]string{"old.html"}, otherDeploys...)...)
+			cfg := testConfig(t, config.Values{"SYNC_MODE": mode})
+
+			if err := deploy(backend, cfg, newDeployStats("test", cfg.Bucket)); err != nil {
+				t.Fatal(err)
+			}
+
+			keys := backend.keys()
+			for _, key := range otherDeploys {
+				if !slices.Contains(keys, key) {
+					t.Errorf("%s was deleted", key)
+				}
+			}
+			if slices.Contains(keys, "old.html") {
+				t.Errorf("leftover old.html was not deleted")
+			}
+			if !slices.Contains(keys, "index.html") {
+				t.Errorf("index.html was not uploaded")
+			}
+		})
+	}
+}
+
+func TestVerifyIgnoresOtherDeploys(t *testing.T) {
+	backend := newMemoryBackend(append([]string{"old.html"}, otherDeploys...)...)
+	cfg := testConfig(t, config.Values{})
+
+	report, err := verify(backend, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Unmanaged, []string{"old.html"}) {
+		t.Errorf("unmanaged = %v, want [old.html]", report.Unmanaged)
+	}
+}
+
+func TestFailedDeployWritesReports(t *testing.T) {
+	reports := t.TempDir()
+	cfg := testConfig(t, config.Values{
+		"FOLDER":       filepath.Join(t.TempDir(), "missing"),
+		"REPORT_JSON":  filepath.Join(reports, "deploy.json"),
+		"REPORT_JUNIT": filepath.Join(reports, "deploy.xml"),
+	})
+	stats := newDeployStats("test", cfg.Bucket)
+
+	err := deploy(newMemoryBackend(), cfg, stats)
+	if err == nil {
+		t.Fatal("deploy of a missing folder succeeded")
+	}
+	finish(cfg, stats, err)
+
+	data, readErr := os.ReadFile(cfg.Report.JSON)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	var report DeployStats
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != StatusFailure || report.Error != err.Error() {
+		t.Errorf("status, error = %q, %q, want %q, %q", report.Status, report.Error, StatusFailure, err)
+	}
+	junit, readErr := os.ReadFile(cfg.Report.JUnit)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if !strings.Contains(string(junit), `<testcase name="deploy" classname="deploy"`) || !strings.Contains(string(junit), `failures="1"`) {
+		t.Errorf("JUnit report has no failed deploy case:\n%s", junit)
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+)
+
+// WriteReports writes the report files enabled in config. A report that
+// cannot be written only warns, the deploy itself is already done.
+func (s *DeployStats) WriteReports(config config.ReportConfig) {
+	if config.JSON != "" {
+		if err := s.WriteJSON(config.JSON); err != nil {
+			logger.Warningf("%v", err)
+		} else {
+			logger.Infof("Wrote JSON report to %s", config.JSON)
+		}
+	}
+	if config.JUnit != "" {
+		if err := s.WriteJUnit(config.JUnit); err != nil {
+			logger.Warningf("%v", err)
+		} else {
+			logger.Infof("Wrote JUnit report to %s", config.JUnit)
+		}
+	}
+}
+
+// WriteJSON writes the statistics with every file result as JSON.
+func (s *DeployStats) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding JSON report: %v", err)
+	}
+	return writeReport(path, append(data, '\n'))
+}
+
+type junitSuites struct {
+	XMLName xml.Name   `xml:"testsuites"`
+	Name    string     `xml:"name,attr"`
+	Tests   int        `xml:"tests,attr"`
+	Skipped int        `xml:"skipped,attr"`
+	Fails   int        `xml:"failures,attr"`
+	Time    float64    `xml:"time,attr"`
+	Suite   junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Fails     int         `xml:"failures,attr"`
+	Time      float64     `xml:"time,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes one test case per file so CI tools render failed
+// uploads and deletes. Skipped files are reported as skipped tests, and a
+// deploy that failed as a failed deploy case.
+func (s *DeployStats) WriteJUnit(path string) error {
+	suite := junitSuite{
+		Name:      fmt.Sprintf("deploy %s to %s", s.DeployID, s.Bucket),
+		Timestamp: s.Started.UTC().Format("2006-01-02T15:04:05"),
+		Tests:     len(s.Files),
+		Skipped:   s.Skipped,
+		Fails:     s.Errors,
+	}
+	for _, t := range s.Timings {
+		suite.Time += t.Duration.Seconds()
+	}
+	for _, r := range s.Files {
+		c := junitCase{
+			Name:      r.Key,
+			Classname: string(r.Action),
+			Time:      r.Duration.Seconds(),
+			SystemOut: r.Reason,
+		}
+		switch r.Action {
+		case ActionFailed:
+			c.Failure = &junitMessage{Message: r.Error, Text: r.Source}
+		case ActionSkipped:
+			c.Skipped = &junitMessage{Message: r.Reason}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	if s.Error != "" {
+		suite.Tests++
+		suite.Fails++
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      "deploy",
+			Classname: "deploy",
+			Failure:   &junitMessage{Message: s.Error},
+		})
+	}
+
+	data, err := xml.MarshalIndent(junitSuites{
+		Name:    suite.Name,
+		Tests:   suite.Tests,
+		Skipped: suite.Skipped,
+		Fails:   suite.Fails,
+		Time:    suite.Time,
+		Suite:   suite,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding JUnit report: %v", err)
+	}
+	return writeReport(path, append([]byte(xml.Header), append(data, '\n')...))
+}
+
+func writeReport(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("Error writing report %s: %v", path, err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("Error writing report %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// ListDeploys returns the deploys recorded in the bucket, oldest first.
+func ListDeploys(config config.Config) ([]Deploy, error) {
+	backend, err := newBackend(config)
+	if err != nil {
+		return nil, err
+	}
+	return listDeploys(backend)
+}
+
+// Rollback restores the objects of deploy id, or of the deploy before the
+// latest one when id is empty. The bucket must have versioning enabled: each
+// object is restored to the last version written before the deploy was
+// recorded. The restored state is recorded as a new deploy so it can be
+// rolled forward again.
+func Rollback(config config.Config, id string) (string, error) {
+	backend, err := newBackend(config)
+	if err != nil {
+		return "", err
+	}
+	return rollback(backend, config, id)
+}
+
+func rollback(backend Backend, config config.Config, id string) (string, error) {
+	deploys, err := listDeploys(backend)
+	if err != nil {
+		return "", err
+	}
+	target, err := findDeploy(deploys, id)
+	if err != nil {
+		return "", err
+	}
+	logger.Infof("Rolling back to deploy %s", target.ID)
+
+	manifest, err := fetchDeployManifest(backend, target.ID)
+	if err != nil {
+		return "", err
+	}
+	current := fetchIncremental(backend)
+
+	logger.Group("Listing object versions")
+	versions, err := backend.ListObjectVersions("")
+	logger.EndGroup()
+	if err != nil {
+		return "", fmt.Errorf("Unable to list object versions: %v", err)
+	}
+	restore, err := versionsAt(manifest, versions, target.Time, config.FileConfig.DefaultACL)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Group("Restoring objects")
+	requests := make(chan restoreRequest)
+	go func() {
+		defer close(requests)
+		for _, request := range restore {
+			requests <- request
+		}
+	}()
+	results := stage(requests, config.Concurrency.Upload, func(request restoreRequest, out chan<- error) {
+		if err := request.restore(backend); err != nil {
+			out <- fmt.Errorf("Error restoring %s: %v", request.key(), err)
+			return
+		}
+		logger.Infof("Restored %s", request.key())
+		out <- nil
+	})
+	var errs []error
+	for err := range results {
+		if err != nil {
+			logger.Errorf("%v", err)
+			errs = append(errs, err)
+		}
+	}
+	logger.EndGroup()
+	if len(errs) > 0 {
+		return "", fmt.Errorf("%d objects could not be restored", len(errs))
+	}
+
+	for key := range manifest.M {
+		current.Delete(types.FileInfo{TargetPath: key})
+	}
+	if current.Size() > 0 {
+		logger.Group("Removing objects added after the deploy")
+		if errs := delete(backend, current, config.Concurrency.Delete, nil); len(errs) > 0 {
+			logger.Warningf("Error while removing objects: %v", errs)
+		}
+		logger.EndGroup()
+	}
+
+	deployID := NewDeployID(time.Now())
+	if err := saveManifest(backend, manifest, deployID); err != nil {
+		return "", err
+	}
+	return deployID, nil
+}
+
+func findDeploy(deploys []Deploy, id string) (Deploy, error) {
+	if id == "" {
+		if len(deploys) < 2 {
+			return Deploy{}, fmt.Errorf("No previous deploy to roll back to")
+		}
+		return deploys[len(deploys)-2], nil
+	}
+
+	for _, deploy := range deploys {
+		if deploy.ID == id {
+			return deploy, nil
+		}
+	}
+	return Deploy{}, fmt.Errorf("Deploy %s not found", id)
+}
+
+// restoreRequest restores an object, either by copying an older version
+// or, for a redirect, by writing it again: copies do not keep the website
+// redirect location of the version.
+type restoreRequest struct {
+	copy     types.CopyObjectRequest
+	redirect *types.PutObjectRequest
+}
+
+func (r restoreRequest) key() string {
+	if r.redirect != nil {
+		return r.redirect.Key
+	}
+	return r.copy.Key
+}
+
+func (r restoreRequest) restore(backend Backend) error {
+	if r.redirect != nil {
+		return backend.PutObject(*r.redirect)
+	}
+	return backend.CopyObject(r.copy)
+}
+
+// versionsAt picks, for every key of manifest, the last version written at
+// or before t and returns the requests needed to make them current again.
+// Redirects are written again from the manifest and need no version.
+// Manifests written before ACLs were recorded fall back to defaultACL.
+func versionsAt(manifest *types.IncrementalConfig, versions []types.ObjectVersion, t time.Time, defaultACL types.ObjectACL) ([]restoreRequest, error) {
+	latest := make(map[string]types.ObjectVersion)
+	for _, v := range versions {
+		if v.LastModified.After(t) {
+			continue
+		}
+		if prev, ok := latest[v.Key]; !ok || v.LastModified.After(prev.LastModified) {
+			latest[v.Key] = v
+		}
+	}
+
+	var restore []restoreRequest
+	for key, value := range manifest.M {
+		v, ok := latest[key]
+		found := ok && !v.IsDeleteMarker && v.VersionID != "" && v.VersionID != "null"
+		if found && v.IsLatest {
+			continue
+		}
+		acl, err := types.ParseObjectACL(string(value.ACL))
+		if value.ACL == "" || err != nil {
+			acl = defaultACL
+		}
+		if value.RedirectLocation != "" {
+			restore = append(restore, restoreRequest{redirect: &types.PutObjectRequest{
+				Key:                     key,
+				Body:                    bytes.NewReader(nil),
+				ContentType:             value.ContentType,
+				CacheControl:            value.CacheControl,
+				ACL:                     acl,
+				WebsiteRedirectLocation: value.RedirectLocation,
+			}})
+			continue
+		}
+		if !found {
+			return nil, fmt.Errorf("No version of %s found for the deploy, is bucket versioning enabled?", key)
+		}
+		restore = append(restore, restoreRequest{copy: types.CopyObjectRequest{
+			Key:       key,
+			VersionID: v.VersionID,
+			ACL:       acl,
+		}})
+	}
+	return restore, nil
+}
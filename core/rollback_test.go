@@ -0,0 +1,62 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+func TestRollbackRestoresRedirects(t *testing.T) {
+	deployed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before, after := deployed.Add(-time.Minute), deployed.Add(time.Hour)
+
+	manifest := types.NewIncrementalConfig()
+	manifest.M["index.html"] = types.IncrementalConfigValue{ContentType: "text/html", ACL: types.PublicReadACL}
+	manifest.M["docs"] = types.IncrementalConfigValue{
+		ContentType:      "text/html",
+		CacheControl:     "no-cache",
+		ACL:              types.PublicReadACL,
+		RedirectLocation: "/docs/",
+	}
+	data, err := manifest.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := NewDeployID(deployed)
+	backend := newMemoryBackend()
+	backend.objects[deployKey(id)] = memoryObject{body: data, lastModified: deployed}
+	backend.objects[deployKey(NewDeployID(after))] = memoryObject{body: []byte("{}"), lastModified: after}
+	backend.objects["index.html"] = memoryObject{body: []byte("<p>new</p>")}
+	backend.objects["docs"] = memoryObject{body: []byte("<p>docs</p>")}
+	backend.bodies["index-1"] = memoryObject{body: []byte("<p>old</p>")}
+	backend.bodies["docs-1"] = memoryObject{redirect: "/docs/"}
+	backend.versions = []types.ObjectVersion{
+		{Key: "index.html", VersionID: "index-1", LastModified: before},
+		{Key: "index.html", VersionID: "index-2", LastModified: after, IsLatest: true},
+		{Key: "docs", VersionID: "docs-1", LastModified: before},
+		{Key: "docs", VersionID: "docs-2", LastModified: after, IsLatest: true},
+	}
+
+	if _, err := rollback(backend, testConfig(t, config.Values{}), id); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(backend.objects["index.html"].body); got != "<p>old</p>" {
+		t.Errorf("index.html = %q, want the old version", got)
+	}
+	docs := backend.objects["docs"]
+	if docs.redirect != "/docs/" || len(docs.body) != 0 {
+		t.Errorf("docs = %+v, want an empty redirect to /docs/", docs)
+	}
+	if docs.contentType != "text/html" || docs.cacheControl != "no-cache" || docs.acl != types.PublicReadACL {
+		t.Errorf("docs metadata = %q %q %q, want the manifest's", docs.contentType, docs.cacheControl, docs.acl)
+	}
+	for _, request := range backend.copies {
+		if request.Key == "docs" {
+			t.Errorf("docs was copied from version %s", request.VersionID)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package core
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/IGLOU-EU/go-wildcard/v2"
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+const (
+	// SitemapKey is the sitemap, or the sitemap index when the pages do not
+	// fit in one.
+	SitemapKey = "sitemap.xml"
+	RobotsKey  = "robots.txt"
+
+	sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	// sitemapMaxURLs is the number of URLs a sitemap may list.
+	sitemapMaxURLs = 50000
+)
+
+func init() {
+	RegisterSiteTransformer("sitemap", func(cfg config.Config) (SiteTransformer, error) {
+		if cfg.Sitemap.BaseURL == "" {
+			return nil, fmt.Errorf("no sitemap base URL given")
+		}
+		return sitemapTransformer{
+			sitemap:       cfg.Sitemap,
+			fileConfig:    cfg.FileConfig,
+			errorDocument: cfg.Website.ErrorDocument,
+		}, nil
+	})
+	RegisterSiteTransformer("robots", func(cfg config.Config) (SiteTransformer, error) {
+		if cfg.Robots == config.NoRobots {
+			return nil, fmt.Errorf("robots is %s, expected %s or %s", cfg.Robots, config.AllowRobots, config.DisallowRobots)
+		}
+		t := robotsTransformer{robots: cfg.Robots, fileConfig: cfg.FileConfig}
+		if slices.Contains(cfg.Transforms, "sitemap") {
+			t.sitemapURL = cfg.Sitemap.BaseURL + "/" + SitemapKey
+		}
+		return t, nil
+	})
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// sitemapTransformer adds SitemapKey listing the HTML pages at the URL they
+// are served at once the .html extension and index documents are dropped.
+// Modification times are left out, they change with every checkout.
+type sitemapTransformer struct {
+	sitemap       config.SitemapConfig
+	fileConfig    config.FileConfig
+	errorDocument string
+}
+
+func (t sitemapTransformer) TransformSite(files []types.FileInfo) ([]types.FileInfo, error) {
+	// a page uploaded to several keys is listed once
+	pages := map[string]string{}
+	for _, file := range files {
+		if file.FileType != types.HTML || file.RedirectLocation != "" || t.isErrorDocument(file.TargetPath) {
+			continue
+		}
+		source := file.SourcePath
+		if source == "" {
+			source = file.TargetPath
+		}
+		page := pagePath(file.TargetPath, t.fileConfig.IndexDocument)
+		if current, ok := pages[source]; !ok || preferPage(page, current) {
+			pages[source] = page
+		}
+	}
+
+	var urls []sitemapURL
+	for _, page := range pages {
+		entry := sitemapURL{Loc: t.url(page)}
+		if rule := t.rule(page); rule != nil {
+			if rule.Exclude {
+				continue
+			}
+			entry.ChangeFreq = rule.ChangeFreq
+			if rule.Priority != nil {
+				entry.Priority = strconv.FormatFloat(*rule.Priority, 'f', 1, 64)
+			}
+		}
+		urls = append(urls, entry)
+	}
+	sort.Slice(urls, func(a, b int) bool {
+		return urls[a].Loc < urls[b].Loc
+	})
+
+	if len(urls) <= sitemapMaxURLs {
+		sitemap, err := t.file(SitemapKey, sitemapURLSet{Xmlns: sitemapNamespace, URLs: urls})
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("Generated %s with %d URLs", SitemapKey, len(urls))
+		return replaceFiles(files, sitemap), nil
+	}
+
+	index := sitemapIndex{Xmlns: sitemapNamespace}
+	var generated []types.FileInfo
+	for start := 0; start < len(urls); start += sitemapMaxURLs {
+		key := fmt.Sprintf("sitemap-%d.xml", len(generated)+1)
+		part, err := t.file(key, sitemapURLSet{Xmlns: sitemapNamespace, URLs: urls[start:min(start+sitemapMaxURLs, len(urls))]})
+		if err != nil {
+			return nil, err
+		}
+		generated = append(generated, part)
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: t.url(key)})
+	}
+	sitemap, err := t.file(SitemapKey, index)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Generated %s with %d URLs in %d parts", SitemapKey, len(urls), len(generated))
+	return replaceFiles(files, append(generated, sitemap)...), nil
+}
+
+func (t sitemapTransformer) isErrorDocument(key string) bool {
+	return t.errorDocument != "" && (key == t.errorDocument || key == strings.TrimSuffix(t.errorDocument, path.Ext(t.errorDocument)))
+}
+
+func (t sitemapTransformer) rule(page string) *config.SitemapRule {
+	for i, rule := range t.sitemap.Rules {
+		if wildcard.Match(rule.Pattern, page) {
+			return &t.sitemap.Rules[i]
+		}
+	}
+	return nil
+}
+
+// url returns the absolute URL of key, escaping every segment.
+func (t sitemapTransformer) url(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return t.sitemap.BaseURL + "/" + strings.Join(segments, "/")
+}
+
+func (t sitemapTransformer) file(key string, document any) (types.FileInfo, error) {
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return types.FileInfo{}, fmt.Errorf("Error encoding %s: %v", key, err)
+	}
+	content := append([]byte(xml.Header), data...)
+	return GeneratedFile(t.fileConfig, key, append(content, '\n')), nil
+}
+
+// pagePath is the URL path of a page uploaded to key: index documents are
+// served for their directory.
+func pagePath(key, indexDocument string) string {
+	if path.Base(key) == indexDocument {
+		return strings.TrimSuffix(key, indexDocument)
+	}
+	return key
+}
+
+// preferPage reports whether page is a better URL than current for the
+// same page: a directory, then a URL without extension.
+func preferPage(page, current string) bool {
+	rank := func(p string) int {
+		switch {
+		case p == "" || strings.HasSuffix(p, "/"):
+			return 0
+		case path.Ext(p) == "":
+			return 1
+		}
+		return 2
+	}
+	if rank(page) != rank(current) {
+		return rank(page) < rank(current)
+	}
+	return page < current
+}
+
+// robotsTransformer adds RobotsKey, allowing crawlers and pointing them at
+// the sitemap, or keeping them out.
+type robotsTransformer struct {
+	robots     config.Robots
+	fileConfig config.FileConfig
+	sitemapURL string
+}
+
+func (t robotsTransformer) TransformSite(files []types.FileInfo) ([]types.FileInfo, error) {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if t.robots == config.DisallowRobots {
+		b.WriteString("Disallow: /\n")
+	} else {
+		b.WriteString("Allow: /\n")
+		if t.sitemapURL != "" {
+			fmt.Fprintf(&b, "\nSitemap: %s\n", t.sitemapURL)
+		}
+	}
+	logger.Infof("Generated %s, crawlers %s", RobotsKey, map[config.Robots]string{
+		config.AllowRobots:    "allowed",
+		config.DisallowRobots: "disallowed",
+	}[t.robots])
+	return replaceFiles(files, GeneratedFile(t.fileConfig, RobotsKey, []byte(b.String()))), nil
+}
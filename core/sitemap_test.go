@@ -0,0 +1,201 @@
+package core
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// generatedFile returns the file at key after the transforms, failing when
+// there is none.
+func generatedFile(t *testing.T, site map[string]string, values config.Values, key string) types.FileInfo {
+	t.Helper()
+	values["FOLDER"] = writeFolder(t, site)
+	files, _, err := ListFiles(testConfig(t, values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if file.TargetPath == key {
+			return file
+		}
+	}
+	t.Fatalf("no %s in %v", key, fileKeys(files))
+	return types.FileInfo{}
+}
+
+func TestSitemap(t *testing.T) {
+	site := map[string]string{
+		"index.html":        "home",
+		"about.html":        "about",
+		"docs/index.html":   "docs",
+		"docs/guide.html":   "guide",
+		"drafts/next.html":  "next",
+		"404.html":          "not found",
+		"a b/ü.html":        "escaped",
+		"style.css":         "a{}",
+		"docs/manual.pdf":   "pdf",
+		"docs/old/old.html": "old",
+	}
+	pages := func(base string, paths ...string) []sitemapURL {
+		urls := make([]sitemapURL, len(paths))
+		for i, p := range paths {
+			urls[i] = sitemapURL{Loc: base + p}
+		}
+		return urls
+	}
+	tests := []struct {
+		name   string
+		values config.Values
+		want   []sitemapURL
+	}{
+		{
+			name:   "index documents",
+			values: config.Values{},
+			want: pages("https://example.com/", "", "404.html", "a%20b/%C3%BC.html", "about.html", "docs/",
+				"docs/guide.html", "docs/old/old.html", "drafts/next.html"),
+		},
+		{
+			name:   "error document left out",
+			values: config.Values{"WEBSITE": "true", "ERROR_DOCUMENT": "404.html", "REMOVE_HTML_EXTENSION": "true"},
+			want: pages("https://example.com/", "", "a%20b/%C3%BC", "about", "docs/", "docs/guide",
+				"docs/old/old", "drafts/next"),
+		},
+		{
+			name:   "duplicated without extension",
+			values: config.Values{"DUPLICATE_HTML_WITH_NO_EXTENSION": "true", "INDEX_MODE": "both"},
+			want: pages("https://example.com/", "", "404", "a%20b/%C3%BC", "about", "docs/",
+				"docs/guide", "docs/old/old", "drafts/next"),
+		},
+		{
+			name:   "base URL with a path",
+			values: config.Values{"SITEMAP_BASE_URL": "https://example.com/site/"},
+			want: pages("https://example.com/site/", "", "404.html", "a%20b/%C3%BC.html", "about.html", "docs/",
+				"docs/guide.html", "docs/old/old.html", "drafts/next.html"),
+		},
+		{
+			name: "rules",
+			values: config.Values{"SITEMAP_RULES": `
+- pattern: "drafts/*"
+  exclude: true
+- pattern: "docs/old/*"
+  priority: 0.1
+  changefreq: never
+- pattern: "docs/*"
+  priority: 0.8
+  changefreq: weekly
+- pattern: "*.html"
+  exclude: true
+`},
+			want: []sitemapURL{
+				{Loc: "https://example.com/"},
+				{Loc: "https://example.com/docs/", Priority: "0.8", ChangeFreq: "weekly"},
+				{Loc: "https://example.com/docs/guide.html", Priority: "0.8", ChangeFreq: "weekly"},
+				{Loc: "https://example.com/docs/old/old.html", Priority: "0.1", ChangeFreq: "never"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.values["SITEMAP"] = "true"
+			if tt.values["SITEMAP_BASE_URL"] == "" {
+				tt.values["SITEMAP_BASE_URL"] = "https://example.com"
+			}
+			file := generatedFile(t, site, tt.values, SitemapKey)
+			if !strings.Contains(file.ContentType, "xml") {
+				t.Errorf("Content-Type = %q, want XML", file.ContentType)
+			}
+			if !strings.HasPrefix(string(file.Content), xml.Header) {
+				t.Errorf("sitemap has no XML header:\n%s", file.Content)
+			}
+			var got sitemapURLSet
+			if err := xml.Unmarshal(file.Content, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Xmlns != sitemapNamespace {
+				t.Errorf("xmlns = %q, want %q", got.Xmlns, sitemapNamespace)
+			}
+			if !reflect.DeepEqual(got.URLs, tt.want) {
+				t.Errorf("urls = %v, want %v", got.URLs, tt.want)
+			}
+		})
+	}
+}
+
+func TestSitemapIndex(t *testing.T) {
+	files := make([]types.FileInfo, sitemapMaxURLs+1)
+	for i := range files {
+		key := fmt.Sprintf("p%06d.html", i)
+		files[i] = types.FileInfo{Name: key, TargetPath: key, SourcePath: key, FileType: types.HTML}
+	}
+	transformed, err := sitemapTransformer{sitemap: config.SitemapConfig{BaseURL: "https://example.com"}}.TransformSite(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := map[string]int{}
+	var index sitemapIndex
+	for _, file := range transformed[len(files):] {
+		if file.TargetPath == SitemapKey {
+			if err := xml.Unmarshal(file.Content, &index); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		var part sitemapURLSet
+		if err := xml.Unmarshal(file.Content, &part); err != nil {
+			t.Fatal(err)
+		}
+		parts[file.TargetPath] = len(part.URLs)
+	}
+	if want := map[string]int{"sitemap-1.xml": sitemapMaxURLs, "sitemap-2.xml": 1}; !reflect.DeepEqual(parts, want) {
+		t.Errorf("parts = %v, want %v", parts, want)
+	}
+	want := []sitemapURL{{Loc: "https://example.com/sitemap-1.xml"}, {Loc: "https://example.com/sitemap-2.xml"}}
+	if !reflect.DeepEqual(index.Sitemaps, want) {
+		t.Errorf("index = %v, want %v", index.Sitemaps, want)
+	}
+}
+
+func TestRobots(t *testing.T) {
+	site := map[string]string{"index.html": "home", "robots.txt": "User-agent: *\nDisallow: /old/\n"}
+	tests := []struct {
+		name   string
+		values config.Values
+		want   string
+	}{
+		{
+			name:   "allow",
+			values: config.Values{"ROBOTS": "allow"},
+			want:   "User-agent: *\nAllow: /\n",
+		},
+		{
+			name:   "allow with sitemap",
+			values: config.Values{"ROBOTS": "allow", "SITEMAP": "true", "SITEMAP_BASE_URL": "https://example.com/site/"},
+			want:   "User-agent: *\nAllow: /\n\nSitemap: https://example.com/site/sitemap.xml\n",
+		},
+		{
+			name:   "disallow",
+			values: config.Values{"ROBOTS": "disallow", "SITEMAP": "true", "SITEMAP_BASE_URL": "https://example.com"},
+			want:   "User-agent: *\nDisallow: /\n",
+		},
+		{
+			name:   "none keeps the file",
+			values: config.Values{"ROBOTS": "none"},
+			want:   "User-agent: *\nDisallow: /old/\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := generatedFile(t, site, tt.values, RobotsKey)
+			if got := uploaded(t, file); got != tt.want {
+				t.Errorf("robots.txt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
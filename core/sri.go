@@ -0,0 +1,236 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+var (
+	// sriTag matches the opening tags of scripts and links.
+	sriTag = regexp.MustCompile(`(?i)<(script|link)\b(?:"[^"]*"|'[^']*'|[^"'>])*>`)
+	// htmlAttribute matches an attribute of a tag and its value, if any.
+	htmlAttribute = regexp.MustCompile(`(?i)\s([a-z][a-z0-9_:.-]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+)
+
+func init() {
+	RegisterSiteTransformer("sri", func(cfg config.Config) (SiteTransformer, error) {
+		return sriTransformer{
+			cspHashes:     cfg.SRI.CSPHashes,
+			fileConfig:    cfg.FileConfig,
+			indexDocument: cfg.FileConfig.IndexDocument,
+		}, nil
+	})
+}
+
+// sriTransformer adds integrity attributes to the <script> and <link> tags
+// of pages loading a file of the site, and lists the hashes of inline
+// scripts. It must run after the transforms changing those files, as the
+// hashes cover the content uploaded.
+type sriTransformer struct {
+	cspHashes     string
+	fileConfig    config.FileConfig
+	indexDocument string
+}
+
+func (t sriTransformer) TransformSite(files []types.FileInfo) ([]types.FileInfo, error) {
+	s := &integrity{
+		files:     map[string]*types.FileInfo{},
+		hashes:    map[string]string{},
+		rewritten: map[string]sriPage{},
+	}
+	for i, file := range files {
+		if file.RedirectLocation == "" {
+			s.files[file.TargetPath] = &files[i]
+		}
+	}
+
+	csp := map[string][]string{}
+	for i := range files {
+		file := &files[i]
+		if file.RedirectLocation != "" || file.FileType != types.HTML {
+			continue
+		}
+		page, ok := s.rewritten[sourceKey(*file)]
+		if !ok {
+			content, err := fileContent(*file)
+			if err != nil {
+				return nil, err
+			}
+			if page, err = s.rewrite(content, referenceBase(*file, t.indexDocument)); err != nil {
+				return nil, err
+			}
+			s.rewritten[sourceKey(*file)] = page
+		}
+		if page.content != nil {
+			file.Content = page.content
+		}
+		if len(page.inline) > 0 {
+			csp[file.TargetPath] = page.inline
+		}
+	}
+	logger.Infof("Added integrity to %d references", s.references)
+
+	if t.cspHashes == "" {
+		return files, nil
+	}
+	data, err := json.MarshalIndent(csp, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding %s: %v", t.cspHashes, err)
+	}
+	logger.Infof("Listed the inline scripts of %d pages in %s", len(csp), t.cspHashes)
+	return replaceFiles(files, GeneratedFile(t.fileConfig, t.cspHashes, append(data, '\n'))), nil
+}
+
+// integrity hashes the files referenced by pages, once each.
+type integrity struct {
+	// files are the files of the site, by key.
+	files  map[string]*types.FileInfo
+	hashes map[string]string
+	// rewritten holds the sources already rewritten by sourceKey, pages
+	// are read once for all their aliases.
+	rewritten  map[string]sriPage
+	references int
+}
+
+// sriPage is a page with its integrity attributes, nil content when no
+// tag was changed, and the CSP sources of its inline scripts.
+type sriPage struct {
+	content []byte
+	inline  []string
+}
+
+// rewrite adds integrity attributes to the tags of content loading a file
+// of the site, resolving relative references against the base key
+// directory. Tags with an integrity attribute are left as they are.
+func (s *integrity) rewrite(content []byte, base string) (sriPage, error) {
+	var page sriPage
+	var out []byte
+	last, changed := 0, false
+	for _, m := range sriTag.FindAllSubmatchIndex(content, -1) {
+		if m[0] < last {
+			// inside a script
+			continue
+		}
+		tag := content[m[0]:m[1]]
+		name := strings.ToLower(string(content[m[2]:m[3]]))
+		attributes := htmlAttributes(tag)
+
+		if name == "script" && attributes["src"] == "" {
+			end := bytes.Index(bytes.ToLower(content[m[1]:]), []byte("</script"))
+			if end < 0 {
+				end = len(content) - m[1]
+			}
+			if script := content[m[1] : m[1]+end]; len(script) > 0 && isExecutable(attributes["type"]) {
+				page.inline = append(page.inline, "'"+sriHash(script)+"'")
+			}
+			out = append(out, content[last:m[1]+end]...)
+			last = m[1] + end
+			continue
+		}
+
+		ref := sriReference(name, attributes)
+		if _, ok := attributes["integrity"]; ok || ref == "" {
+			continue
+		}
+		key, ok := referenceKey(ref, base)
+		if !ok {
+			continue
+		}
+		hash, err := s.hash(key)
+		if err != nil {
+			return sriPage{}, err
+		}
+		if hash == "" {
+			logger.Debugf("Not adding integrity to %s, no file is uploaded to %s", ref, key)
+			continue
+		}
+		insert := m[1] - 1
+		if bytes.HasSuffix(tag, []byte("/>")) {
+			insert--
+			for content[insert-1] == ' ' {
+				insert--
+			}
+		}
+		out = append(out, content[last:insert]...)
+		out = append(out, ` integrity="`+hash+`"`...)
+		last, changed = insert, true
+		s.references++
+	}
+	if changed {
+		page.content = append(out, content[last:]...)
+	}
+	return page, nil
+}
+
+// hash returns the integrity value of the file at key, empty when there is
+// none.
+func (s *integrity) hash(key string) (string, error) {
+	if hash, ok := s.hashes[key]; ok {
+		return hash, nil
+	}
+	file, ok := s.files[key]
+	if !ok {
+		s.hashes[key] = ""
+		return "", nil
+	}
+	content, err := fileContent(*file)
+	if err != nil {
+		return "", err
+	}
+	s.hashes[key] = sriHash(content)
+	return s.hashes[key], nil
+}
+
+// sriReference returns the URL of the script or stylesheet a tag loads,
+// empty for other links.
+func sriReference(name string, attributes map[string]string) string {
+	if name == "script" {
+		return attributes["src"]
+	}
+	rel := strings.Fields(strings.ToLower(attributes["rel"]))
+	for _, r := range rel {
+		switch r {
+		case "stylesheet", "modulepreload":
+			return attributes["href"]
+		case "preload":
+			if as := strings.ToLower(attributes["as"]); as == "script" || as == "style" {
+				return attributes["href"]
+			}
+		}
+	}
+	return ""
+}
+
+// htmlAttributes returns the attributes of tag by lowercase name, unquoted.
+func htmlAttributes(tag []byte) map[string]string {
+	attributes := map[string]string{}
+	for _, m := range htmlAttribute.FindAllSubmatch(tag, -1) {
+		value := string(m[2])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		attributes[strings.ToLower(string(m[1]))] = value
+	}
+	return attributes
+}
+
+// isExecutable reports whether a script of the given type is run by the
+// browser, rather than holding data such as JSON.
+func isExecutable(kind string) bool {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	return kind == "" || kind == "module" || strings.Contains(kind, "javascript") || strings.Contains(kind, "ecmascript")
+}
+
+func sriHash(content []byte) string {
+	sum := sha512.Sum384(content)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
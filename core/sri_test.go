@@ -0,0 +1,121 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+func TestSRI(t *testing.T) {
+	app, style := "app()", "a{color:red}"
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{
+			name: "script and stylesheet",
+			page: `<link rel="stylesheet" href="css/style.css"><script src="/app.js"></script>`,
+			want: `<link rel="stylesheet" href="css/style.css" integrity="` + sriHash([]byte(style)) + `"><script src="/app.js" integrity="` + sriHash([]byte(app)) + `"></script>`,
+		},
+		{
+			name: "self-closing tag",
+			page: `<link rel=stylesheet href=css/style.css />`,
+			want: `<link rel=stylesheet href=css/style.css integrity="` + sriHash([]byte(style)) + `" />`,
+		},
+		{
+			name: "preloads",
+			page: `<link rel="preload" as="script" href="app.js"><link rel="modulepreload" href="app.js"><link rel="preload" as="image" href="app.js">`,
+			want: `<link rel="preload" as="script" href="app.js" integrity="` + sriHash([]byte(app)) + `"><link rel="modulepreload" href="app.js" integrity="` + sriHash([]byte(app)) + `"><link rel="preload" as="image" href="app.js">`,
+		},
+		{
+			name: "left as they are",
+			page: `<script src="app.js" integrity="sha256-x"></script><script src="https://cdn.example.com/app.js"></script><script src="missing.js"></script><link rel="icon" href="app.js">`,
+			want: `<script src="app.js" integrity="sha256-x"></script><script src="https://cdn.example.com/app.js"></script><script src="missing.js"></script><link rel="icon" href="app.js">`,
+		},
+		{
+			name: "tags inside inline scripts",
+			page: `<script>document.write('<script src="app.js"></script>')</script>`,
+			want: `<script>document.write('<script src="app.js"></script>')</script>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := transformedFiles(t, map[string]string{
+				"index.html":    tt.page,
+				"app.js":        app,
+				"css/style.css": style,
+			}, config.Values{"SRI": "true"})
+			if got := uploaded(t, files["index.html"]); got != tt.want {
+				t.Errorf("index.html =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Integrity covers the content uploaded, e.g. once minified.
+func TestSRIAfterMinify(t *testing.T) {
+	files := transformedFiles(t, map[string]string{
+		"index.html": `<script src="app.js"></script>`,
+		"app.js":     "app( 1 )",
+	}, config.Values{"SRI": "true", "MINIFY": "true"})
+	want := `<script src="app.js" integrity="` + sriHash([]byte("app(1)")) + `"></script>`
+	if got := uploaded(t, files["index.html"]); got != want {
+		t.Errorf("index.html = %s, want %s", got, want)
+	}
+}
+
+func TestSRICSPHashes(t *testing.T) {
+	inline := "run()"
+	file := generatedFile(t, map[string]string{
+		"index.html":      `<script>` + inline + `</script><script type="application/ld+json">{}</script><script type="module">` + inline + `</script>`,
+		"about.html":      `<script src="app.js"></script>`,
+		"docs/index.html": `<script>` + inline + `</script>`,
+		"app.js":          "app()",
+	}, config.Values{"SRI": "true", "SRI_CSP_HASHES": "/csp.json", "INDEX_MODE": "slash"}, "csp.json")
+
+	var got map[string][]string
+	if err := json.Unmarshal(file.Content, &got); err != nil {
+		t.Fatal(err)
+	}
+	hash := "'" + sriHash([]byte(inline)) + "'"
+	want := map[string][]string{
+		"index.html":      {hash, hash},
+		"docs/":           {hash},
+		"docs/index.html": {hash},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("csp.json = %v, want %v", got, want)
+	}
+}
+
+// Pages generated by earlier transforms have no source and are rewritten
+// each on their own.
+func TestSRIGeneratedPages(t *testing.T) {
+	fileConfig := config.FileConfig{IndexDocument: "index.html"}
+	files := []types.FileInfo{
+		GeneratedFile(fileConfig, "a.js", []byte("a()")),
+		GeneratedFile(fileConfig, "b.js", []byte("b()")),
+		GeneratedFile(fileConfig, "a.html", []byte(`<script src="a.js"></script>`)),
+		GeneratedFile(fileConfig, "b.html", []byte(`<script src="b.js"></script>`)),
+		GeneratedFile(fileConfig, "plain.html", []byte(`<p>plain</p>`)),
+	}
+	transformed, err := sriTransformer{fileConfig: fileConfig, indexDocument: "index.html"}.TransformSite(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"a.html":     `<script src="a.js" integrity="` + sriHash([]byte("a()")) + `"></script>`,
+		"b.html":     `<script src="b.js" integrity="` + sriHash([]byte("b()")) + `"></script>`,
+		"plain.html": `<p>plain</p>`,
+	}
+	for _, file := range transformed {
+		if content, ok := want[file.TargetPath]; ok && string(file.Content) != content {
+			t.Errorf("%s = %s, want %s", file.TargetPath, file.Content, content)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/minify"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// maxSummaryRows caps the file tables of the job summary, which is limited
+// to 1MiB per step.
+const maxSummaryRows = 500
+
+// DeployStats summarises a deploy for the step outputs, the job summary and
+// the report files.
+type DeployStats struct {
+	DeployID string    `json:"deploy_id"`
+	Bucket   string    `json:"bucket"`
+	Started  time.Time `json:"started"`
+	// Status is StatusSuccess, StatusPartial when files failed, or
+	// StatusFailure with the Error that stopped the deploy.
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	Uploaded      int    `json:"uploaded"`
+	Skipped       int    `json:"skipped"`
+	Deleted       int    `json:"deleted"`
+	Errors        int    `json:"errors"`
+	BytesUploaded int64  `json:"bytes_uploaded"`
+	// InvalidationID is the CDN invalidation of the changed paths, if any.
+	InvalidationID string `json:"invalidation_id,omitempty"`
+	// Minified sums up minification by file type.
+	Minified []MinifyResult `json:"minified,omitempty"`
+	Files    []FileResult   `json:"files"`
+	Timings  []StageTiming  `json:"timings"`
+}
+
+// MinifyResult is what minification saved on the files of one type.
+type MinifyResult struct {
+	Type       string `json:"type"`
+	Files      int    `json:"files"`
+	Bytes      int64  `json:"bytes"`
+	BytesSaved int64  `json:"bytes_saved"`
+}
+
+// FileAction is what a deploy did with a file or object.
+type FileAction string
+
+const (
+	ActionUploaded FileAction = "uploaded"
+	ActionSkipped  FileAction = "skipped"
+	// ActionMetadataUpdated is an upload of unchanged content with new
+	// Cache-Control, Content-Type or ACL.
+	ActionMetadataUpdated FileAction = "metadata-updated"
+	ActionDeleted         FileAction = "deleted"
+	ActionFailed          FileAction = "failed"
+)
+
+// FileResult is the outcome of one file or object of the deploy.
+type FileResult struct {
+	Source   string        `json:"source,omitempty"`
+	Key      string        `json:"key"`
+	Action   FileAction    `json:"action"`
+	Reason   string        `json:"reason,omitempty"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// StageTiming is the time spent in one stage of the deploy.
+type StageTiming struct {
+	Stage    string        `json:"stage"`
+	Duration time.Duration `json:"duration"`
+}
+
+func newDeployStats(id, bucket string) *DeployStats {
+	return &DeployStats{
+		DeployID: id,
+		Bucket:   bucket,
+		Started:  time.Now(),
+		Files:    []FileResult{},
+		Timings:  []StageTiming{},
+	}
+}
+
+// finish sets the status of the deploy, failed when deployErr is set.
+func (s *DeployStats) finish(deployErr error) {
+	switch {
+	case deployErr != nil:
+		s.Status = StatusFailure
+		s.Error = deployErr.Error()
+	case s.Errors > 0:
+		s.Status = StatusPartial
+	default:
+		s.Status = StatusSuccess
+	}
+}
+
+// track records the time spent in stage since start.
+func (s *DeployStats) track(stage string, start time.Time) {
+	s.Timings = append(s.Timings, StageTiming{Stage: stage, Duration: time.Since(start)})
+}
+
+// record adds the outcome of a file to the counters. A nil *DeployStats
+// records nothing.
+func (s *DeployStats) record(result FileResult) {
+	if s == nil {
+		return
+	}
+	switch result.Action {
+	case ActionUploaded, ActionMetadataUpdated:
+		s.Uploaded++
+		s.BytesUploaded += result.Bytes
+	case ActionSkipped:
+		s.Skipped++
+	case ActionDeleted:
+		s.Deleted++
+	case ActionFailed:
+		s.Errors++
+	}
+	s.Files = append(s.Files, result)
+}
+
+// recordMinified sums up the minified files by type. Bytes is the size of
+// the files once minified.
+func (s *DeployStats) recordMinified(files []types.FileInfo) {
+	byType := map[string]*MinifyResult{}
+	for _, file := range files {
+		if file.MinifiedBytes == 0 {
+			continue
+		}
+		kind := minify.Type(file.Name)
+		result, ok := byType[kind]
+		if !ok {
+			result = &MinifyResult{Type: kind}
+			byType[kind] = result
+		}
+		result.Files++
+		result.Bytes += file.Size
+		result.BytesSaved += file.MinifiedBytes
+	}
+	s.Minified = nil
+	for _, result := range byType {
+		s.Minified = append(s.Minified, *result)
+	}
+	sort.Slice(s.Minified, func(a, b int) bool {
+		return s.Minified[a].Type < s.Minified[b].Type
+	})
+}
+
+// filter returns the results with one of actions.
+func (s *DeployStats) filter(actions ...FileAction) []FileResult {
+	var results []FileResult
+	for _, r := range s.Files {
+		for _, action := range actions {
+			if r.Action == action {
+				results = append(results, r)
+				break
+			}
+		}
+	}
+	return results
+}
+
+// ChangedPaths returns the uploaded and deleted keys, sorted.
+func (s *DeployStats) ChangedPaths() []string {
+	var paths []string
+	for _, r := range s.filter(ActionUploaded, ActionMetadataUpdated, ActionDeleted) {
+		paths = append(paths, r.Key)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Publish sets the step outputs and adds the job summary.
+func (s *DeployStats) Publish() {
+	sort.SliceStable(s.Files, func(a, b int) bool {
+		return s.Files[a].Key < s.Files[b].Key
+	})
+
+	logger.SetOutput("deploy-id", s.DeployID)
+	logger.SetOutput("uploaded-count", strconv.Itoa(s.Uploaded))
+	logger.SetOutput("skipped-count", strconv.Itoa(s.Skipped))
+	logger.SetOutput("deleted-count", strconv.Itoa(s.Deleted))
+	logger.SetOutput("error-count", strconv.Itoa(s.Errors))
+	logger.SetOutput("bytes-uploaded", strconv.FormatInt(s.BytesUploaded, 10))
+	logger.SetOutput("changed-paths", strings.Join(s.ChangedPaths(), "\n"))
+	if s.InvalidationID != "" {
+		logger.SetOutput("invalidation-id", s.InvalidationID)
+	}
+	logger.AddStepSummary(s.Markdown())
+}
+
+// Markdown renders the statistics, the minification savings, the changed
+// files and the timings.
+func (s *DeployStats) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Deploy `%s`\n\n", s.DeployID)
+	if s.Error != "" {
+		fmt.Fprintf(&b, "**Failed:** %s\n\n", s.Error)
+	}
+	b.WriteString("| Uploaded | Skipped | Deleted | Errors | Bytes uploaded |\n")
+	b.WriteString("| ---: | ---: | ---: | ---: | ---: |\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %s |\n\n", s.Uploaded, s.Skipped, s.Deleted, s.Errors, formatBytes(s.BytesUploaded))
+	if s.InvalidationID != "" {
+		fmt.Fprintf(&b, "CDN invalidation `%s` submitted.\n\n", s.InvalidationID)
+	}
+
+	if len(s.Minified) > 0 {
+		b.WriteString("| Minified | Files | Size | Saved |\n| --- | ---: | ---: | ---: |\n")
+		for _, m := range s.Minified {
+			saved := float64(m.BytesSaved) / float64(m.Bytes+m.BytesSaved) * 100
+			fmt.Fprintf(&b, "| %s | %d | %s | %s (%.0f%%) |\n", m.Type, m.Files, formatBytes(m.Bytes), formatBytes(m.BytesSaved), saved)
+		}
+		b.WriteString("\n")
+	}
+
+	if changed := s.filter(ActionUploaded, ActionMetadataUpdated, ActionDeleted, ActionFailed); len(changed) > 0 {
+		b.WriteString("<details><summary>Changed files</summary>\n\n")
+		b.WriteString("| Key | Action | Reason | Size |\n| --- | --- | --- | ---: |\n")
+		for i, r := range changed {
+			if i == maxSummaryRows {
+				fmt.Fprintf(&b, "| … and %d more | | | |\n", len(changed)-i)
+				break
+			}
+			reason := r.Reason
+			if r.Error != "" {
+				reason = r.Error
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", r.Key, r.Action, reason, formatBytes(r.Bytes))
+		}
+		b.WriteString("\n</details>\n\n")
+	}
+
+	b.WriteString("| Stage | Duration |\n| --- | ---: |\n")
+	for _, t := range s.Timings {
+		fmt.Fprintf(&b, "| %s | %s |\n", t.Stage, t.Duration.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/types"
+	"github.com/rizaldntr/storage-service-website-action/utils"
+)
+
+// throttledBackend shares a request limiter and a bandwidth limiter across
+// every call made to the wrapped backend, whichever stage makes it.
+type throttledBackend struct {
+	backend   Backend
+	requests  *utils.RateLimiter
+	bandwidth *utils.RateLimiter
+}
+
+// throttle wraps backend with the limits of config, or returns it unchanged
+// when no limit is set.
+func throttle(backend Backend, config config.ThrottleConfig) Backend {
+	if config.BytesPerSecond <= 0 && config.RequestsPerSecond <= 0 {
+		return backend
+	}
+
+	return &throttledBackend{
+		backend:   backend,
+		requests:  utils.NewRateLimiter(config.RequestsPerSecond, config.RequestsPerSecond),
+		bandwidth: utils.NewRateLimiter(float64(config.BytesPerSecond), float64(config.BytesPerSecond)),
+	}
+}
+
+func (t *throttledBackend) GetObject(key string) ([]byte, error) {
+	t.requests.Wait()
+	data, err := t.backend.GetObject(key)
+	t.bandwidth.WaitN(int64(len(data)))
+	return data, err
+}
+
+func (t *throttledBackend) HeadObject(key string) (types.ObjectInfo, error) {
+	t.requests.Wait()
+	return t.backend.HeadObject(key)
+}
+
+func (t *throttledBackend) ListObjects(prefix string) ([]types.ObjectInfo, error) {
+	t.requests.Wait()
+	return t.backend.ListObjects(prefix)
+}
+
+func (t *throttledBackend) ListObjectVersions(prefix string) ([]types.ObjectVersion, error) {
+	t.requests.Wait()
+	return t.backend.ListObjectVersions(prefix)
+}
+
+func (t *throttledBackend) CopyObject(request types.CopyObjectRequest) error {
+	t.requests.Wait()
+	return t.backend.CopyObject(request)
+}
+
+func (t *throttledBackend) PutObject(request types.PutObjectRequest) error {
+	t.requests.Wait()
+	request.Body = utils.LimitReader(request.Body, t.bandwidth)
+	return t.backend.PutObject(request)
+}
+
+func (t *throttledBackend) CreateMultipartUpload(request types.PutObjectRequest) (string, error) {
+	t.requests.Wait()
+	return t.backend.CreateMultipartUpload(request)
+}
+
+func (t *throttledBackend) UploadPart(request types.UploadPartRequest) (types.CompletedPart, error) {
+	t.requests.Wait()
+	request.Body = utils.LimitReadSeeker(request.Body, t.bandwidth)
+	return t.backend.UploadPart(request)
+}
+
+func (t *throttledBackend) CompleteMultipartUpload(key, uploadID string, algorithm types.HashAlgorithm, parts []types.CompletedPart) error {
+	t.requests.Wait()
+	return t.backend.CompleteMultipartUpload(key, uploadID, algorithm, parts)
+}
+
+func (t *throttledBackend) AbortMultipartUpload(key, uploadID string) error {
+	t.requests.Wait()
+	return t.backend.AbortMultipartUpload(key, uploadID)
+}
+
+func (t *throttledBackend) DeleteObject(key string) error {
+	t.requests.Wait()
+	return t.backend.DeleteObject(key)
+}
+
+func (t *throttledBackend) DeleteObjects(keys []string) error {
+	t.requests.Wait()
+	return t.backend.DeleteObjects(keys)
+}
+
+func (t *throttledBackend) EmptyBucket() error {
+	t.requests.Wait()
+	return t.backend.EmptyBucket()
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+// Transformer changes the files of a deploy between walking and uploading.
+type Transformer interface {
+	// Applies reports whether file is given to Transform. Other files are
+	// passed on without being read.
+	Applies(file types.FileInfo) bool
+	// Transform returns the files uploaded in place of file: itself,
+	// modified or not, none to drop it, or more to add objects. A file
+	// whose content changes carries it in Content, see GeneratedFile for
+	// new objects. Transform is called concurrently.
+	Transform(file types.FileInfo, content []byte) ([]types.FileInfo, error)
+}
+
+// SiteTransformer changes the files of a deploy seeing every file at once,
+// e.g. to relate them to each other or to add objects listing them.
+type SiteTransformer interface {
+	// TransformSite returns the files uploaded in place of files.
+	TransformSite(files []types.FileInfo) ([]types.FileInfo, error)
+}
+
+// TransformerFactory builds a transformer from the configuration.
+type TransformerFactory func(config config.Config) (Transformer, error)
+
+// SiteTransformerFactory builds a site transformer from the configuration.
+type SiteTransformerFactory func(config config.Config) (SiteTransformer, error)
+
+var (
+	transformersMu sync.Mutex
+	transformers   = map[string]SiteTransformerFactory{}
+)
+
+// RegisterTransformer makes a transformer available under name to the
+// transforms setting. It panics if name is already registered.
+func RegisterTransformer(name string, factory TransformerFactory) {
+	RegisterSiteTransformer(name, func(cfg config.Config) (SiteTransformer, error) {
+		t, err := factory(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return fileTransformer{Transformer: t, workers: cfg.Concurrency.Hash}, nil
+	})
+}
+
+// RegisterSiteTransformer makes a site transformer available under name to
+// the transforms setting. It panics if name is already registered.
+func RegisterSiteTransformer(name string, factory SiteTransformerFactory) {
+	transformersMu.Lock()
+	defer transformersMu.Unlock()
+
+	if _, ok := transformers[name]; ok {
+		panic("transformer " + name + " registered twice")
+	}
+	transformers[name] = factory
+}
+
+// Transformers returns the names of the registered transformers, sorted.
+func Transformers() []string {
+	transformersMu.Lock()
+	defer transformersMu.Unlock()
+
+	names := make([]string, 0, len(transformers))
+	for name := range transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newTransformer(name string, config config.Config) (SiteTransformer, error) {
+	transformersMu.Lock()
+	factory, ok := transformers[name]
+	transformersMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown transform %q, expected one of %s", name, strings.Join(Transformers(), ", "))
+	}
+	t, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating transform %s: %v", name, err)
+	}
+	return t, nil
+}
+
+// GeneratedFile returns a new object holding content at key, with the
+// headers fileConfig gives to a file of that name.
+func GeneratedFile(fileConfig config.FileConfig, key string, content []byte) types.FileInfo {
+	file := types.FileInfo{
+		ACL:        fileConfig.DefaultACL,
+		Name:       path.Base(key),
+		TargetPath: key,
+		Content:    content,
+		Size:       int64(len(content)),
+	}
+	setCacheControlAndFileType(fileConfig, &file)
+	processRegexConfig(&file, fileConfig.ObjectRules)
+	return file
+}
+
+// replaceFiles adds generated to files, replacing the files already at
+// their keys.
+func replaceFiles(files []types.FileInfo, generated ...types.FileInfo) []types.FileInfo {
+	keys := map[string]bool{}
+	for _, file := range generated {
+		keys[file.TargetPath] = true
+	}
+	kept := files[:0:0]
+	for _, file := range files {
+		if keys[file.TargetPath] {
+			logger.Warningf("Replacing %s with a generated file", file.TargetPath)
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return append(kept, generated...)
+}
+
+// transform applies the configured transforms in order and returns the
+// files sorted by key again.
+func transform(files []types.FileInfo, config config.Config) ([]types.FileInfo, error) {
+	for _, name := range config.Transforms {
+		t, err := newTransformer(name, config)
+		if err != nil {
+			return nil, err
+		}
+		if files, err = t.TransformSite(files); err != nil {
+			return nil, fmt.Errorf("Error in transform %s: %v", name, err)
+		}
+		logger.Debugf("Applied transform %s, %d files", name, len(files))
+	}
+
+	for i := range files {
+		if files[i].Content != nil {
+			files[i].Size = int64(len(files[i].Content))
+		}
+	}
+	sort.SliceStable(files, func(a, b int) bool {
+		return files[a].TargetPath < files[b].TargetPath
+	})
+	for i := 1; i < len(files); i++ {
+		if files[i].TargetPath == files[i-1].TargetPath {
+			return nil, fmt.Errorf("Key %s is written more than once after transforms", files[i].TargetPath)
+		}
+	}
+	return files, nil
+}
+
+// fileTransformer runs a Transformer over the files of a site on workers
+// goroutines.
+type fileTransformer struct {
+	Transformer
+	workers int
+}
+
+func (t fileTransformer) TransformSite(files []types.FileInfo) ([]types.FileInfo, error) {
+	type result struct {
+		files []types.FileInfo
+		err   error
+	}
+	results := stage(emit(files), t.workers, func(file types.FileInfo, out chan<- result) {
+		if !t.Applies(file) {
+			out <- result{files: []types.FileInfo{file}}
+			return
+		}
+		content, err := fileContent(file)
+		if err != nil {
+			out <- result{err: err}
+			return
+		}
+		transformed, err := t.Transform(file, content)
+		for i := range transformed {
+			// an unchanged file is still uploaded from disk
+			if transformed[i].SourcePath == file.SourcePath && file.Content == nil && bytes.Equal(transformed[i].Content, content) {
+				transformed[i].Content = nil
+			}
+		}
+		out <- result{files: transformed, err: err}
+	})
+
+	var transformed []types.FileInfo
+	var firstErr error
+	for result := range results {
+		if result.err != nil && firstErr == nil {
+			firstErr = result.err
+		}
+		transformed = append(transformed, result.files...)
+	}
+	return transformed, firstErr
+}
+
+// sourceKey identifies the content of file, shared by the keys a source is
+// uploaded to. Generated files have no source and are identified by key.
+func sourceKey(file types.FileInfo) string {
+	if file.SourcePath == "" {
+		return file.TargetPath
+	}
+	return file.SourcePath
+}
+
+// fileContent returns what would be uploaded for file.
+func fileContent(file types.FileInfo) ([]byte, error) {
+	switch {
+	case file.Content != nil:
+		return file.Content, nil
+	case file.RedirectLocation != "":
+		return nil, nil
+	}
+	content, err := os.ReadFile(file.SourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file %s: %v", file.SourcePath, err)
+	}
+	return content, nil
+}
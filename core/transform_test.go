@@ -0,0 +1,157 @@
+package core
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/types"
+)
+
+func init() {
+	for _, suffix := range []string{"a", "b"} {
+		RegisterTransformer("test-suffix-"+suffix, func(cfg config.Config) (Transformer, error) {
+			return suffixTransformer(suffix), nil
+		})
+	}
+	RegisterSiteTransformer("test-count", func(cfg config.Config) (SiteTransformer, error) {
+		return countTransformer{}, nil
+	})
+	RegisterTransformer("test-failing", func(cfg config.Config) (Transformer, error) {
+		return nil, errors.New("no token")
+	})
+}
+
+// suffixTransformer appends itself to the content of .txt files.
+type suffixTransformer string
+
+func (t suffixTransformer) Applies(file types.FileInfo) bool {
+	return strings.HasSuffix(file.Name, ".txt")
+}
+
+func (t suffixTransformer) Transform(file types.FileInfo, content []byte) ([]types.FileInfo, error) {
+	file.Content = append(append([]byte{}, content...), t...)
+	return []types.FileInfo{file}, nil
+}
+
+// countTransformer adds count.txt, holding the number of files before it.
+type countTransformer struct{}
+
+func (countTransformer) TransformSite(files []types.FileInfo) ([]types.FileInfo, error) {
+	count := strconv.Itoa(len(files))
+	return append(files, GeneratedFile(config.FileConfig{}, "count.txt", []byte(count))), nil
+}
+
+func TestTransformOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		transforms []string
+		want       map[string]string
+	}{
+		{"none", nil, map[string]string{"a.txt": "x", "b.css": "y"}},
+		{"in order", []string{"test-suffix-a", "test-suffix-b"}, map[string]string{"a.txt": "xab", "b.css": "y"}},
+		{"reversed", []string{"test-suffix-b", "test-suffix-a"}, map[string]string{"a.txt": "xba", "b.css": "y"}},
+		{
+			name:       "site transformer between",
+			transforms: []string{"test-suffix-a", "test-count", "test-suffix-b"},
+			want:       map[string]string{"a.txt": "xab", "b.css": "y", "count.txt": "2b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := []types.FileInfo{
+				{Name: "b.css", TargetPath: "b.css", Content: []byte("y")},
+				{Name: "a.txt", TargetPath: "a.txt", Content: []byte("x")},
+			}
+			cfg := config.Config{Transforms: tt.transforms, Concurrency: config.ConcurrencyConfig{Hash: 2}}
+			transformed, err := transform(files, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			var keys []string
+			for _, file := range transformed {
+				got[file.TargetPath] = string(file.Content)
+				keys = append(keys, file.TargetPath)
+				if file.Size != int64(len(file.Content)) {
+					t.Errorf("%s has size %d for %d bytes", file.TargetPath, file.Size, len(file.Content))
+				}
+			}
+			if !slices.IsSorted(keys) {
+				t.Errorf("keys = %v, want them sorted", keys)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+			for key, content := range tt.want {
+				if got[key] != content {
+					t.Errorf("%s = %q, want %q", key, got[key], content)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		transforms []string
+		want       string
+	}{
+		{"unknown", []string{"test-suffix-a", "nope"}, `Unknown transform "nope", expected one of `},
+		{"factory error", []string{"test-failing"}, "Error creating transform test-failing: no token"},
+		{"key written twice", []string{"test-count", "test-count"}, "Key count.txt is written more than once after transforms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := []types.FileInfo{{Name: "a.txt", TargetPath: "a.txt", Content: []byte("x")}}
+			_, err := transform(files, config.Config{Transforms: tt.transforms})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("transform() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformers(t *testing.T) {
+	names := Transformers()
+	for _, name := range []string{"build-info", "fingerprint", "minify", "robots", "sitemap", "sri", "test-count", "test-suffix-a"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("Transformers() = %v, want %s listed", names, name)
+		}
+	}
+	if !slices.IsSorted(names) {
+		t.Errorf("Transformers() = %v, want them sorted", names)
+	}
+}
+
+func TestRegisterTransformerTwice(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func()
+	}{
+		{"transformer", func() {
+			RegisterTransformer("test-suffix-a", func(cfg config.Config) (Transformer, error) {
+				return suffixTransformer("c"), nil
+			})
+		}},
+		{"site transformer", func() {
+			RegisterSiteTransformer("minify", func(cfg config.Config) (SiteTransformer, error) {
+				return countTransformer{}, nil
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering a name twice did not panic")
+				}
+			}()
+			tt.register()
+		})
+	}
+}
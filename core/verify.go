@@ -0,0 +1,174 @@
+package core
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/types"
+	"github.com/rizaldntr/storage-service-website-action/utils"
+)
+
+// Verifier is the read-only subset of a backend needed to detect drift.
+type Verifier interface {
+	GetObject(key string) ([]byte, error)
+	HeadObject(key string) (types.ObjectInfo, error)
+	ListObjects(prefix string) ([]types.ObjectInfo, error)
+}
+
+// Mismatch describes a single attribute of an object that differs from what
+// the local folder would produce.
+type Mismatch struct {
+	Key      string `json:"key"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// DriftReport is the result of comparing the bucket, the .incremental
+// manifest and the local folder.
+type DriftReport struct {
+	Bucket string `json:"bucket"`
+	// Unmanaged lists objects present in the bucket but not in the manifest.
+	Unmanaged []string `json:"unmanaged"`
+	// Missing lists manifest entries that no longer exist in the bucket.
+	Missing []string `json:"missing"`
+	// Mismatched lists objects that differ from the local folder.
+	Mismatched []Mismatch `json:"mismatched"`
+}
+
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Unmanaged) > 0 || len(r.Missing) > 0 || len(r.Mismatched) > 0
+}
+
+func Verify(config config.Config) (*DriftReport, error) {
+	backend, err := newBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return verify(backend, config)
+}
+
+func verify(backend Verifier, config config.Config) (*DriftReport, error) {
+	report := &DriftReport{
+		Bucket:     config.Bucket,
+		Unmanaged:  []string{},
+		Missing:    []string{},
+		Mismatched: []Mismatch{},
+	}
+
+	logger.Group("Fetching .incremental and bucket listing")
+	manifest := types.NewIncrementalConfig()
+	ibytes, err := backend.GetObject(IncrementalConfig)
+	if err != nil && !errors.Is(err, types.ObjectNotFoundError) {
+		logger.EndGroup()
+		return nil, err
+	}
+	if err == nil {
+		if err := manifest.UnmarshalJSON(ibytes); err != nil {
+			logger.EndGroup()
+			return nil, err
+		}
+	}
+
+	objects, err := backend.ListObjects("")
+	if err != nil {
+		logger.EndGroup()
+		return nil, err
+	}
+	logger.Infof("Manifest has %d entries, bucket has %d objects", manifest.Size(), len(objects))
+	logger.EndGroup()
+
+	remote := make(map[string]types.ObjectInfo, len(objects))
+	for _, obj := range managedObjects(objects, config.Preview.Prefix) {
+		remote[obj.Key] = obj
+		if _, ok := manifest.M[obj.Key]; !ok {
+			report.Unmanaged = append(report.Unmanaged, obj.Key)
+		}
+	}
+	for key := range manifest.M {
+		if _, ok := remote[key]; !ok {
+			report.Missing = append(report.Missing, key)
+		}
+	}
+
+	logger.Group("Comparing objects with local folder")
+	localFiles, _, err := ListFiles(config)
+	if err != nil {
+		logger.EndGroup()
+		return nil, err
+	}
+	files := HashFiles(emit(localFiles), config.Concurrency.Hash, LoadHashCache(config.HashCache), "")
+	results := stage(files, config.Concurrency.Upload, func(file types.FileInfo, out chan<- []Mismatch) {
+		if _, ok := remote[file.TargetPath]; !ok {
+			out <- []Mismatch{{
+				Key:      file.TargetPath,
+				Field:    "object",
+				Expected: "present",
+				Actual:   "missing",
+			}}
+			return
+		}
+
+		head, err := backend.HeadObject(file.TargetPath)
+		if err != nil {
+			logger.Errorf("Unable to fetch metadata of %s: %v", file.TargetPath, err)
+			out <- []Mismatch{{
+				Key:      file.TargetPath,
+				Field:    "object",
+				Expected: "present",
+				Actual:   err.Error(),
+			}}
+			return
+		}
+		out <- compareObject(file, head, config.Multipart.ChunkSize)
+	})
+	for mismatches := range results {
+		report.Mismatched = append(report.Mismatched, mismatches...)
+	}
+	logger.EndGroup()
+
+	sort.Strings(report.Unmanaged)
+	sort.Strings(report.Missing)
+	sort.Slice(report.Mismatched, func(a, b int) bool {
+		if report.Mismatched[a].Key != report.Mismatched[b].Key {
+			return report.Mismatched[a].Key < report.Mismatched[b].Key
+		}
+		return report.Mismatched[a].Field < report.Mismatched[b].Field
+	})
+
+	return report, nil
+}
+
+func compareObject(file types.FileInfo, head types.ObjectInfo, chunkSize int64) []Mismatch {
+	var mismatches []Mismatch
+	remote := types.IncrementalConfigValue{ETag: head.ETag, Size: head.Size}
+	if !matchesETag(file, remote, chunkSize) {
+		expected, _ := utils.ETagFromMD5(file.ContentMD5)
+		mismatches = append(mismatches, Mismatch{
+			Key:      file.TargetPath,
+			Field:    "etag",
+			Expected: expected,
+			Actual:   head.ETag,
+		})
+	}
+	if file.ContentType != head.ContentType {
+		mismatches = append(mismatches, Mismatch{
+			Key:      file.TargetPath,
+			Field:    "content-type",
+			Expected: file.ContentType,
+			Actual:   head.ContentType,
+		})
+	}
+	if file.CacheControl != head.CacheControl {
+		mismatches = append(mismatches, Mismatch{
+			Key:      file.TargetPath,
+			Field:    "cache-control",
+			Expected: file.CacheControl,
+			Actual:   head.CacheControl,
+		})
+	}
+	return mismatches
+}
@@ -0,0 +1,222 @@
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+	"github.com/rizaldntr/storage-service-website-action/logger"
+	"github.com/rizaldntr/storage-service-website-action/utils"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the payload, as
+	// sha256=<hex>, when a webhook secret is set.
+	SignatureHeader = "X-Deploy-Signature-256"
+	// EventHeader names the event of the payload.
+	EventHeader = "X-Deploy-Event"
+
+	PreDeployEvent = "pre-deploy"
+	DeployEvent    = "deploy"
+)
+
+// Deploy statuses sent to webhooks.
+const (
+	StatusSuccess = "success"
+	// StatusPartial is a finished deploy with files that failed.
+	StatusPartial = "partial"
+	StatusFailure = "failure"
+)
+
+var (
+	webhookClient = &http.Client{Timeout: 30 * time.Second}
+	// webhookBackoff is the delay before the first retry, doubled for each
+	// following one.
+	webhookBackoff = time.Second
+)
+
+// WebhookPayload is the JSON body sent to webhooks.
+type WebhookPayload struct {
+	Event       string         `json:"event"`
+	Status      string         `json:"status,omitempty"`
+	DeployID    string         `json:"deploy_id"`
+	Bucket      string         `json:"bucket"`
+	Prefix      string         `json:"prefix,omitempty"`
+	Environment string         `json:"environment,omitempty"`
+	Workflow    utils.Workflow `json:"workflow"`
+	// Files is the number of local files, sent before the deploy.
+	Files          int             `json:"files,omitempty"`
+	Summary        *WebhookSummary `json:"summary,omitempty"`
+	ChangedPaths   []string        `json:"changed_paths,omitempty"`
+	InvalidationID string          `json:"invalidation_id,omitempty"`
+	Error          string          `json:"error,omitempty"`
+}
+
+// WebhookSummary holds the counters of a finished deploy.
+type WebhookSummary struct {
+	Uploaded      int   `json:"uploaded"`
+	Skipped       int   `json:"skipped"`
+	Deleted       int   `json:"deleted"`
+	Errors        int   `json:"errors"`
+	BytesUploaded int64 `json:"bytes_uploaded"`
+}
+
+// preDeployResponse is the optional body of a pre-deploy hook response.
+type preDeployResponse struct {
+	Allow  *bool  `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+func newWebhookPayload(event string, config config.Config, stats *DeployStats) WebhookPayload {
+	return WebhookPayload{
+		Event:       event,
+		DeployID:    stats.DeployID,
+		Bucket:      config.Bucket,
+		Prefix:      config.Prefix,
+		Environment: config.Environment,
+		Workflow:    utils.CurrentWorkflow(),
+	}
+}
+
+// preDeploy asks the pre-deploy hook whether the deploy may go ahead. The
+// hook vetoes it with an error status, or with {"allow": false} and an
+// optional reason. A hook that cannot be reached vetoes the deploy too.
+func preDeploy(config config.Config, stats *DeployStats, files int) error {
+	if config.Webhooks.PreDeploy == "" {
+		return nil
+	}
+
+	payload := newWebhookPayload(PreDeployEvent, config, stats)
+	payload.Files = files
+	status, body, err := sendWebhook(config.Webhooks.PreDeploy, payload, config.Webhooks)
+	if err != nil {
+		return fmt.Errorf("Error calling pre-deploy hook: %v", err)
+	}
+
+	var response preDeployResponse
+	json.Unmarshal(body, &response)
+	if status >= 300 || (response.Allow != nil && !*response.Allow) {
+		reason := response.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("status %d", status)
+		}
+		return fmt.Errorf("Deploy vetoed by pre-deploy hook: %s", reason)
+	}
+	logger.Infof("Deploy allowed by pre-deploy hook")
+	return nil
+}
+
+// notify sends the result of the deploy to every webhook. Failed webhooks
+// are only warned about, the deploy is already done.
+func notify(config config.Config, stats *DeployStats) {
+	if len(config.Webhooks.URLs) == 0 {
+		return
+	}
+
+	payload := newWebhookPayload(DeployEvent, config, stats)
+	payload.Summary = &WebhookSummary{
+		Uploaded:      stats.Uploaded,
+		Skipped:       stats.Skipped,
+		Deleted:       stats.Deleted,
+		Errors:        stats.Errors,
+		BytesUploaded: stats.BytesUploaded,
+	}
+	payload.ChangedPaths = stats.ChangedPaths()
+	payload.InvalidationID = stats.InvalidationID
+	payload.Status = stats.Status
+	payload.Error = stats.Error
+
+	logger.Group("Sending webhooks")
+	defer logger.EndGroup()
+	for _, url := range config.Webhooks.URLs {
+		status, _, err := sendWebhook(url, payload, config.Webhooks)
+		if err == nil && status >= 300 {
+			err = fmt.Errorf("unexpected status %d", status)
+		}
+		if err != nil {
+			logger.Warningf("Error sending webhook to %s: %v", webhookHost(url), err)
+			continue
+		}
+		logger.Infof("Sent webhook to %s", webhookHost(url))
+	}
+}
+
+// sendWebhook posts payload to url and returns the status and body of the
+// response. Network errors, 429 and 5xx responses are retried with an
+// exponential backoff.
+func sendWebhook(url string, payload WebhookPayload, config config.WebhookConfig) (int, []byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var status int
+	var data []byte
+	for attempt := 0; ; attempt++ {
+		status, data, err = postWebhook(url, payload.Event, body, config.Secret)
+		retry := err != nil || status == http.StatusTooManyRequests || status >= 500
+		if !retry || attempt >= config.Retries {
+			return status, data, err
+		}
+		delay := webhookBackoff << attempt
+		logger.Debugf("Retrying webhook to %s in %s (status %d, error %v)", webhookHost(url), delay, status, err)
+		time.Sleep(delay)
+	}
+}
+
+func postWebhook(webhook, event string, body []byte, secret string) (int, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "storage-service-website-action")
+	req.Header.Set(EventHeader, event)
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(body, secret))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		// drop the URL from the error, it may hold a token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	// responses are only read for a veto, which is short
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// webhookHost shortens a webhook URL for logs, as URLs of chat webhooks
+// embed their token.
+func webhookHost(webhook string) string {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Sign returns the value of SignatureHeader for body, for receivers to
+// compare against with hmac.Equal.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
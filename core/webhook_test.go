@@ -0,0 +1,190 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/config"
+)
+
+func init() {
+	webhookBackoff = time.Millisecond
+}
+
+func TestSign(t *testing.T) {
+	// RFC 4231, test case 2
+	got := Sign([]byte("what do ya want for nothing?"), "Jefe")
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+// webhookServer answers the requests it receives with the given statuses in
+// turn, the last one repeated. A zero status drops the connection.
+func webhookServer(t *testing.T, statuses []int, body string) (*httptest.Server, func() []*http.Request) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(data)))
+		mu.Lock()
+		requests = append(requests, r)
+		status := statuses[min(len(requests), len(statuses))-1]
+		mu.Unlock()
+		if status == 0 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestSendWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		attempts int
+		status   int
+		err      bool
+	}{
+		{"success", []int{200}, 3, 1, 200, false},
+		{"429 and 5xx", []int{503, 429, 200}, 3, 3, 200, false},
+		{"network error", []int{0, 0, 204}, 3, 3, 204, false},
+		{"retries exhausted", []int{500}, 2, 3, 500, false},
+		{"network error exhausted", []int{0}, 1, 2, 0, true},
+		{"no retry on 4xx", []int{404, 200}, 3, 1, 404, false},
+		{"no retries", []int{503, 200}, 0, 1, 503, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := webhookServer(t, tt.statuses, "")
+			status, _, err := sendWebhook(server.URL, WebhookPayload{Event: DeployEvent}, config.WebhookConfig{Retries: tt.retries})
+			if (err != nil) != tt.err {
+				t.Errorf("err = %v, want error %v", err, tt.err)
+			}
+			if status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+			if len(requests()) != tt.attempts {
+				t.Errorf("sent %d requests, want %d", len(requests()), tt.attempts)
+			}
+		})
+	}
+}
+
+func TestSendWebhookSignature(t *testing.T) {
+	server, requests := webhookServer(t, []int{200}, "")
+	payload := WebhookPayload{Event: PreDeployEvent, DeployID: "d1"}
+	if _, _, err := sendWebhook(server.URL, payload, config.WebhookConfig{Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := requests()[0]
+	body, _ := io.ReadAll(r.Body)
+	if got, want := r.Header.Get(SignatureHeader), Sign(body, "s3cret"); got != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+	if got := r.Header.Get(EventHeader); got != PreDeployEvent {
+		t.Errorf("%s = %q, want %q", EventHeader, got, PreDeployEvent)
+	}
+}
+
+func TestPreDeploy(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		veto   string
+	}{
+		{"allowed", 200, ``, ""},
+		{"allowed explicitly", 200, `{"allow": true}`, ""},
+		{"vetoed by status", 403, ``, "status 403"},
+		{"vetoed by status with reason", 409, `{"reason": "deploy in progress"}`, "deploy in progress"},
+		{"vetoed by body", 200, `{"allow": false, "reason": "release freeze"}`, "release freeze"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := webhookServer(t, []int{tt.status}, tt.body)
+			cfg := config.Config{Bucket: "site", Webhooks: config.WebhookConfig{PreDeploy: server.URL}}
+
+			err := preDeploy(cfg, newDeployStats("d1", "site"), 12)
+			switch {
+			case tt.veto == "" && err != nil:
+				t.Errorf("err = %v, want the deploy allowed", err)
+			case tt.veto != "" && (err == nil || !strings.Contains(err.Error(), tt.veto)):
+				t.Errorf("err = %v, want a veto with %q", err, tt.veto)
+			}
+
+			var payload WebhookPayload
+			json.NewDecoder(requests()[0].Body).Decode(&payload)
+			if payload.Event != PreDeployEvent || payload.Files != 12 || payload.DeployID != "d1" {
+				t.Errorf("payload = %+v", payload)
+			}
+		})
+	}
+}
+
+func TestPreDeployUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	cfg := config.Config{Webhooks: config.WebhookConfig{PreDeploy: server.URL}}
+
+	err := preDeploy(cfg, newDeployStats("d1", "site"), 1)
+	if err == nil || !strings.Contains(err.Error(), "Error calling pre-deploy hook") {
+		t.Errorf("err = %v, want the deploy vetoed", err)
+	}
+}
+
+func TestNotifyStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		errors int
+		err    error
+		status string
+	}{
+		{"success", 0, nil, StatusSuccess},
+		{"partial", 2, nil, StatusPartial},
+		{"failure", 0, errors.New("access denied"), StatusFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := webhookServer(t, []int{200}, "")
+			cfg := config.Config{Bucket: "site", Webhooks: config.WebhookConfig{URLs: []string{server.URL}}}
+			stats := newDeployStats("d1", "site")
+			stats.Errors = tt.errors
+
+			stats.finish(tt.err)
+			notify(cfg, stats)
+
+			var payload WebhookPayload
+			json.NewDecoder(requests()[0].Body).Decode(&payload)
+			if payload.Event != DeployEvent || payload.Status != tt.status {
+				t.Errorf("event %s, status %s, want %s, %s", payload.Event, payload.Status, DeployEvent, tt.status)
+			}
+			if tt.err != nil && payload.Error != tt.err.Error() {
+				t.Errorf("error = %q, want %q", payload.Error, tt.err)
+			}
+			if payload.Summary == nil || payload.Summary.Errors != tt.errors {
+				t.Errorf("summary = %+v", payload.Summary)
+			}
+		})
+	}
+}
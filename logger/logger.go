@@ -0,0 +1,130 @@
+// Package logger writes progress either as GitHub Actions workflow commands
+// or, outside of a workflow, as plain human readable lines on stderr.
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sethvargo/go-githubactions"
+)
+
+var (
+	mu      sync.Mutex
+	action  = os.Getenv("GITHUB_ACTIONS") == "true"
+	verbose bool
+	out     io.Writer = os.Stderr
+)
+
+// SetAction switches between workflow commands and human readable output.
+func SetAction(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	action = enabled
+}
+
+// IsAction reports whether output is written as workflow commands.
+func IsAction() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return action
+}
+
+// SetVerbose enables debug messages in human readable output. Workflow debug
+// messages are controlled by the runner instead.
+func SetVerbose(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	verbose = enabled
+}
+
+func printf(prefix, msg string, args ...any) {
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Fprintf(out, prefix+msg+"\n", args...)
+}
+
+func Debugf(msg string, args ...any) {
+	if IsAction() {
+		githubactions.Debugf(msg, args...)
+		return
+	}
+	mu.Lock()
+	enabled := verbose
+	mu.Unlock()
+	if enabled {
+		printf("debug: ", msg, args...)
+	}
+}
+
+func Infof(msg string, args ...any) {
+	if IsAction() {
+		githubactions.Infof(msg, args...)
+		return
+	}
+	printf("", msg, args...)
+}
+
+func Noticef(msg string, args ...any) {
+	if IsAction() {
+		githubactions.Noticef(msg, args...)
+		return
+	}
+	printf("notice: ", msg, args...)
+}
+
+func Warningf(msg string, args ...any) {
+	if IsAction() {
+		githubactions.Warningf(msg, args...)
+		return
+	}
+	printf("warning: ", msg, args...)
+}
+
+func Errorf(msg string, args ...any) {
+	if IsAction() {
+		githubactions.Errorf(msg, args...)
+		return
+	}
+	printf("error: ", msg, args...)
+}
+
+// Fatalf prints an error and exits with status 1.
+func Fatalf(msg string, args ...any) {
+	Errorf(msg, args...)
+	os.Exit(1)
+}
+
+func Group(title string) {
+	if IsAction() {
+		githubactions.Group(title)
+		return
+	}
+	printf("==> ", "%s", title)
+}
+
+func EndGroup() {
+	if IsAction() {
+		githubactions.EndGroup()
+	}
+}
+
+// SetOutput sets an output of the step. Outside of a workflow it is only
+// printed as a debug message.
+func SetOutput(name, value string) {
+	if IsAction() {
+		githubactions.SetOutput(name, value)
+		return
+	}
+	Debugf("output %s=%s", name, value)
+}
+
+// AddStepSummary appends Markdown to the job summary. Outside of a workflow
+// there is no summary and it is dropped.
+func AddStepSummary(markdown string) {
+	if IsAction() {
+		githubactions.AddStepSummary(markdown)
+	}
+}
@@ -1,11 +1,62 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rizaldntr/storage-service-website-action/cli"
 	"github.com/rizaldntr/storage-service-website-action/config"
 	"github.com/rizaldntr/storage-service-website-action/core"
+	"github.com/rizaldntr/storage-service-website-action/logger"
 )
 
 func main() {
+	// Arguments are only given when run from a terminal, the action runs
+	// without any and reads its inputs from the environment.
+	if len(os.Args) > 1 {
+		cli.Main()
+	}
+
 	cfg := config.Get()
-	core.Process(cfg)
+	switch cfg.Mode {
+	case config.VerifyMode:
+		verify(cfg)
+	case config.PreviewMode:
+		if _, err := core.DeployPreview(cfg); err != nil {
+			logger.Fatalf("Preview deploy failed: %v", err)
+		}
+	case config.CleanupPreviewMode:
+		if _, err := core.CleanupPreview(cfg); err != nil {
+			logger.Fatalf("Preview cleanup failed: %v", err)
+		}
+	case config.GCPreviewsMode:
+		if _, err := core.CollectPreviews(cfg, time.Now()); err != nil {
+			logger.Fatalf("Preview garbage collection failed: %v", err)
+		}
+	default:
+		if _, err := core.Process(cfg); err != nil {
+			logger.Fatalf("Deploy failed: %v", err)
+		}
+	}
+}
+
+func verify(cfg config.Config) {
+	report, err := core.Verify(cfg)
+	if err != nil {
+		logger.Fatalf("Verify failed: %v", err)
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logger.Fatalf("Failed to marshal drift report: %v", err)
+	}
+	fmt.Println(string(out))
+
+	if report.HasDrift() {
+		logger.Fatalf("Drift detected: %d unmanaged, %d missing, %d mismatched",
+			len(report.Unmanaged), len(report.Missing), len(report.Mismatched))
+	}
+	logger.Infof("No drift detected")
 }
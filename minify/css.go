@@ -0,0 +1,82 @@
+package minify
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	// cssNoSpaceAfter and cssNoSpaceBefore are the characters whitespace
+	// can be dropped next to. A space before a colon is kept, it separates
+	// a pseudo-class from the selector before it, and so is one before a
+	// parenthesis, as in "and (max-width: 600px)".
+	cssNoSpaceAfter  = "{};,:>"
+	cssNoSpaceBefore = "{};,>)!"
+)
+
+// CSS removes comments and the whitespace that does not separate tokens.
+// Comments starting with /*! are kept, they usually hold a license.
+func CSS(content []byte) ([]byte, error) {
+	s := content
+	out := make([]byte, 0, len(s))
+	space := false
+	emit := func(b ...byte) {
+		if space && len(out) > 0 &&
+			!strings.ContainsRune(cssNoSpaceAfter, rune(out[len(out)-1])) &&
+			!strings.ContainsRune(cssNoSpaceBefore, rune(b[0])) {
+			out = append(out, ' ')
+		}
+		space = false
+		out = append(out, b...)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case isSpace(c):
+			space = true
+			i++
+		case c == '/' && i+1 < len(s) && s[i+1] == '*':
+			end := bytes.Index(s[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + 4
+			}
+			if i+2 < len(s) && s[i+2] == '!' {
+				emit(s[i:end]...)
+			} else {
+				space = true
+			}
+			i = end
+		case c == '"' || c == '\'':
+			end := quoted(s, i)
+			emit(s[i:end]...)
+			i = end
+		case (c == 'u' || c == 'U') && i+4 <= len(s) && strings.EqualFold(string(s[i:i+4]), "url("):
+			// unquoted URLs may hold anything but a closing parenthesis
+			end := i + 4
+			for end < len(s) && s[end] != ')' {
+				if s[end] == '"' || s[end] == '\'' {
+					end = quoted(s, end)
+					continue
+				}
+				end++
+			}
+			if end < len(s) {
+				end++
+			}
+			emit(s[i:end]...)
+			i = end
+		case c == '}' && len(out) > 0 && out[len(out)-1] == ';':
+			out = out[:len(out)-1]
+			space = false
+			emit(c)
+			i++
+		default:
+			emit(c)
+			i++
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,29 @@
+package minify
+
+import "testing"
+
+func TestCSS(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"rule", "a {\n  color: red;\n  margin: 0 auto;\n}", "a{color:red;margin:0 auto}"},
+		{"comments", "/* one */ a { b: c } /*! MIT */", "a{b:c}/*! MIT */"},
+		{"media query", "@media screen and (max-width: 600px) { a { b: c } }", "@media screen and (max-width:600px){a{b:c}}"},
+		{"descendant pseudo-class", "a :hover { b: c }", "a :hover{b:c}"},
+		{"child combinator", "a > b { c: d }", "a>b{c:d}"},
+		{"important", "a { b: c !important; }", "a{b:c!important}"},
+		{"string", "a::after { content: \"a  b\" }", "a::after{content:\"a  b\"}"},
+		{"unquoted url", "a { background: url( a b.png ) }", "a{background:url( a b.png )}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CSS([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("CSS(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package minify
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlRawElements hold text that is copied as it is, or minified by
+	// its own minifier.
+	htmlRawElements = []string{"pre", "textarea", "script", "style"}
+	scriptType      = regexp.MustCompile(`(?i)\stype\s*=\s*["']?([^"'\s>]+)`)
+)
+
+// HTML removes comments and collapses whitespace in text to a single space.
+// Tags are copied as they are. The content of <pre> and <textarea> is kept,
+// <style> is minified as CSS, and <script> as JavaScript or JSON depending
+// on its type, other scripts and invalid JSON are kept as they are.
+// Conditional comments are kept.
+func HTML(content []byte) ([]byte, error) {
+	s := content
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case bytes.HasPrefix(s[i:], []byte("<!--")):
+			end := bytes.Index(s[i+4:], []byte("-->"))
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + 7
+			}
+			if bytes.HasPrefix(s[i:], []byte("<!--[if")) || bytes.HasPrefix(s[i:], []byte("<!--<![endif]")) {
+				out = append(out, s[i:end]...)
+			}
+			i = end
+		case c == '<':
+			end := tagEnd(s, i)
+			tag := s[i:end]
+			out = append(out, tag...)
+			i = end
+			if name := rawElement(tag); name != "" {
+				close := closingTag(s, i, name)
+				inner, err := minifyRaw(name, tag, s[i:close])
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, inner...)
+				i = close
+			}
+		case isSpace(c):
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			// a removed comment may leave two spaces in a row
+			if len(out) == 0 || out[len(out)-1] != ' ' {
+				out = append(out, ' ')
+			}
+		default:
+			out = append(out, c)
+			i++
+		}
+	}
+	return bytes.TrimSpace(out), nil
+}
+
+// tagEnd returns the index after the tag starting at i, skipping over
+// quoted attribute values.
+func tagEnd(s []byte, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '"', '\'':
+			j = quoted(s, j) - 1
+		case '>':
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// rawElement returns the name of the raw text element opened by tag.
+func rawElement(tag []byte) string {
+	if len(tag) < 2 || tag[1] == '/' || bytes.HasSuffix(tag, []byte("/>")) {
+		return ""
+	}
+	lower := strings.ToLower(string(tag[1:]))
+	for _, name := range htmlRawElements {
+		if strings.HasPrefix(lower, name) && len(lower) > len(name) && (isSpace(lower[len(name)]) || lower[len(name)] == '>') {
+			return name
+		}
+	}
+	return ""
+}
+
+// closingTag returns the index of the tag closing the element name whose
+// content starts at i.
+func closingTag(s []byte, i int, name string) int {
+	end := bytes.Index(bytes.ToLower(s[i:]), []byte("</"+name))
+	if end < 0 {
+		return len(s)
+	}
+	return i + end
+}
+
+func minifyRaw(name string, tag, content []byte) ([]byte, error) {
+	switch name {
+	case "style":
+		return CSS(content)
+	case "script":
+		kind := "text/javascript"
+		if m := scriptType.FindSubmatch(tag); m != nil {
+			kind = strings.ToLower(string(m[1]))
+		}
+		switch {
+		case kind == "module" || strings.Contains(kind, "javascript") || strings.Contains(kind, "ecmascript"):
+			return JS(content)
+		case strings.HasSuffix(kind, "json"):
+			// invalid JSON is left for the page to deal with
+			if minified, err := JSON(bytes.TrimSpace(content)); err == nil {
+				return minified, nil
+			}
+		}
+	}
+	return content, nil
+}
@@ -0,0 +1,31 @@
+package minify
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"whitespace", "<p>\n  a   b\n</p>\n", "<p> a b </p>"},
+		{"comments", "<p>a <!-- b --> c</p><!--[if IE]>x<![endif]-->", "<p>a c</p><!--[if IE]>x<![endif]-->"},
+		{"pre", "<pre>  a\n   b  </pre>", "<pre>  a\n   b  </pre>"},
+		{"textarea", "<textarea>  a\n   b  </textarea>", "<textarea>  a\n   b  </textarea>"},
+		{"style", "<style> a { b: c; } </style>", "<style>a{b:c}</style>"},
+		{"script", "<script>\n  var a = 1;\n</script>", "<script>var a=1;</script>"},
+		{"module script", "<script type=\"module\"> import a  from 'a' </script>", "<script type=\"module\">import a from'a'</script>"},
+		{"template script", "<script type=\"text/x-template\">  <b>  a  </b>  </script>", "<script type=\"text/x-template\">  <b>  a  </b>  </script>"},
+		{"json script", "<script type=\"application/ld+json\">\n{ \"a\": [1, 2] }\n</script>", "<script type=\"application/ld+json\">{\"a\":[1,2]}</script>"},
+		{"invalid json script", "<script type=application/ld+json> { \"a\": 1, } </script>  <p> a </p>", "<script type=application/ld+json> { \"a\": 1, } </script> <p> a </p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTML([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("HTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package minify
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	// jsNoNewlineAfter and jsNoNewlineBefore are the characters a line
+	// break can be dropped next to without changing where semicolons are
+	// inserted. Other line breaks are kept.
+	jsNoNewlineAfter  = "{;,([=:?&|*%^<>!~."
+	jsNoNewlineBefore = ")]},;"
+)
+
+// jsRegexpKeywords are the keywords a regular expression may follow.
+var jsRegexpKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true, "yield": true, "await": true,
+}
+
+// JS removes comments and the whitespace that does not separate tokens.
+// Strings, template literals and regular expressions are copied as they
+// are, and line breaks are only removed where no semicolon could be
+// inserted in their place. Comments starting with /*! are kept.
+func JS(content []byte) ([]byte, error) {
+	s := content
+	out := make([]byte, 0, len(s))
+	space, newline := false, false
+	emit := func(b ...byte) {
+		if len(out) > 0 && (space || newline) {
+			prev, next := out[len(out)-1], b[0]
+			switch {
+			case newline && !strings.ContainsRune(jsNoNewlineAfter, rune(prev)) && !strings.ContainsRune(jsNoNewlineBefore, rune(next)):
+				out = append(out, '\n')
+			case jsNeedsSpace(prev, next):
+				out = append(out, ' ')
+			}
+		}
+		space, newline = false, false
+		out = append(out, b...)
+	}
+
+	i := 0
+	if bytes.HasPrefix(s, []byte("#!")) {
+		// a hashbang line
+		i = bytes.IndexByte(s, '\n')
+		if i < 0 {
+			i = len(s)
+		}
+		out = append(out, s[:i]...)
+	}
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case isSpace(c):
+			if c == '\n' {
+				newline = true
+			}
+			space = true
+			i++
+		case c == '/' && i+1 < len(s) && s[i+1] == '/':
+			end := bytes.IndexByte(s[i:], '\n')
+			if end < 0 {
+				end = len(s) - i
+			}
+			i += end
+		case c == '/' && i+1 < len(s) && s[i+1] == '*':
+			end := bytes.Index(s[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + 4
+			}
+			comment := s[i:end]
+			if bytes.HasPrefix(comment, []byte("/*!")) {
+				emit(comment...)
+			} else if bytes.IndexByte(comment, '\n') >= 0 {
+				newline = true
+			} else {
+				space = true
+			}
+			i = end
+		case c == '/' && jsRegexpAllowed(out):
+			end := jsRegexpEnd(s, i)
+			if end < 0 {
+				emit(c)
+				i++
+				continue
+			}
+			emit(s[i:end]...)
+			i = end
+		case c == '"' || c == '\'':
+			end := quoted(s, i)
+			emit(s[i:end]...)
+			i = end
+		case c == '`':
+			end := jsTemplateEnd(s, i)
+			emit(s[i:end]...)
+			i = end
+		default:
+			emit(c)
+			i++
+		}
+	}
+	return out, nil
+}
+
+func isJSIdent(c byte) bool {
+	return c == '_' || c == '$' || c == '\\' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// jsNeedsSpace reports whether prev and next would form another token
+// without a space between them.
+func jsNeedsSpace(prev, next byte) bool {
+	switch {
+	case isJSIdent(prev) && isJSIdent(next):
+		return true
+	case (prev == '+' || prev == '-') && prev == next:
+		return true
+	case prev == '/' && (next == '/' || next == '*'):
+		return true
+	case '0' <= prev && prev <= '9' && next == '.':
+		return true
+	}
+	return false
+}
+
+// jsRegexpAllowed reports whether a slash after out starts a regular
+// expression rather than a division. Guessing a regular expression wrong
+// only copies text as it is.
+func jsRegexpAllowed(out []byte) bool {
+	if len(out) == 0 {
+		return true
+	}
+	prev := out[len(out)-1]
+	switch {
+	case prev == ')' || prev == ']' || prev == '"' || prev == '\'' || prev == '`':
+		return false
+	case (prev == '+' || prev == '-') && len(out) > 1 && out[len(out)-2] == prev:
+		// x++ / 2
+		return false
+	case isJSIdent(prev):
+		start := len(out)
+		for start > 0 && isJSIdent(out[start-1]) {
+			start--
+		}
+		return jsRegexpKeywords[string(out[start:])]
+	}
+	return true
+}
+
+// jsRegexpEnd returns the index after the regular expression starting at
+// i, with its flags, or -1 when it does not end on the same line.
+func jsRegexpEnd(s []byte, i int) int {
+	class := false
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '\n':
+			return -1
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				j++
+				for j < len(s) && isJSIdent(s[j]) {
+					j++
+				}
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// jsTemplateEnd returns the index after the template literal starting at
+// i, skipping over the code of its substitutions.
+func jsTemplateEnd(s []byte, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			return j + 1
+		case '$':
+			if j+1 < len(s) && s[j+1] == '{' {
+				j = jsBlockEnd(s, j+2) - 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// jsBlockEnd returns the index after the brace closing the code at i.
+func jsBlockEnd(s []byte, i int) int {
+	depth := 1
+	for j := i; j < len(s); {
+		switch s[j] {
+		case '"', '\'':
+			j = quoted(s, j)
+			continue
+		case '`':
+			j = jsTemplateEnd(s, j)
+			continue
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+		j++
+	}
+	return len(s)
+}
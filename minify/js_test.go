@@ -0,0 +1,36 @@
+package minify
+
+import "testing"
+
+func TestJS(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"comments", "a = 1; // one\n/* two */ b = 2;", "a=1;b=2;"},
+		{"license comment", "/*! MIT */\nvar a = 1;", "/*! MIT */\nvar a=1;"},
+		{"newline before parenthesis", "a = b\n(f)()", "a=b\n(f)()"},
+		{"newline before increment", "a\n++b", "a\n++b"},
+		{"newline after operator", "a =\n  b", "a=b"},
+		{"division", "x / 2 / y", "x/2/y"},
+		{"regexp with slash in class", "/[/]/g.test(s)", "/[/]/g.test(s)"},
+		{"regexp after keyword", "return /a b/.test(s)", "return/a b/.test(s)"},
+		{"brace in template substitution", "`${'}'}`  +  x", "`${'}'}`+x"},
+		{"template whitespace", "`a  b ${ c }`", "`a  b ${ c }`"},
+		{"string whitespace", "s = 'a  // b'", "s='a  // b'"},
+		{"negated minus", "b - -c", "b- -c"},
+		{"plus plus", "b + +c", "b+ +c"},
+		{"number property", "1 .toString()", "1 .toString()"},
+		{"identifiers", "var  a = typeof  b", "var a=typeof b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JS([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("JS(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Package minify removes comments and whitespace from web files. The
+// minifiers are conservative: they never rename identifiers or rewrite
+// values, so their output behaves like the input.
+package minify
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+type minifier func(content []byte) ([]byte, error)
+
+var minifiers = map[string]minifier{
+	".html": HTML,
+	".htm":  HTML,
+	".css":  CSS,
+	".js":   JS,
+	".mjs":  JS,
+	".json": JSON,
+	".svg":  SVG,
+}
+
+// Supports reports whether a file called name can be minified. Files named
+// like app.min.js are taken as minified already.
+func Supports(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	if strings.HasSuffix(strings.ToLower(strings.TrimSuffix(name, path.Ext(name))), ".min") {
+		return false
+	}
+	_, ok := minifiers[ext]
+	return ok
+}
+
+// Type returns the type a file called name is minified as, e.g. "css".
+func Type(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	switch ext {
+	case ".htm":
+		return "html"
+	case ".mjs":
+		return "js"
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// Minify minifies content by the extension of name. Content of a type that
+// is not supported is returned as is.
+func Minify(name string, content []byte) ([]byte, error) {
+	m, ok := minifiers[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return content, nil
+	}
+	return m(content)
+}
+
+// JSON removes the whitespace between tokens.
+func JSON(content []byte) ([]byte, error) {
+	var b bytes.Buffer
+	if err := json.Compact(&b, content); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// quoted returns the index after the string starting at i, which opens
+// with a quote, or len(s) when it is not closed.
+func quoted(s []byte, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(s)
+}
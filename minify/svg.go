@@ -0,0 +1,76 @@
+package minify
+
+import (
+	"bytes"
+	"strings"
+)
+
+// svgTextElements are the elements whose whitespace is rendered.
+var svgTextElements = []string{"text", "tspan", "textPath", "title", "desc"}
+
+// SVG removes comments and the whitespace between tags, except inside text
+// elements where it is collapsed to a single space. Tags, CDATA sections
+// and <style> content are copied as they are.
+func SVG(content []byte) ([]byte, error) {
+	s := content
+	out := make([]byte, 0, len(s))
+	text := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case bytes.HasPrefix(s[i:], []byte("<!--")):
+			end := bytes.Index(s[i+4:], []byte("-->"))
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + 7
+			}
+			i = end
+		case bytes.HasPrefix(s[i:], []byte("<![CDATA[")):
+			end := bytes.Index(s[i:], []byte("]]>"))
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + 3
+			}
+			out = append(out, s[i:end]...)
+			i = end
+		case c == '<':
+			end := tagEnd(s, i)
+			tag := s[i:end]
+			out = append(out, tag...)
+			i = end
+			if name := svgTextElement(tag); name != "" && !bytes.HasSuffix(tag, []byte("/>")) {
+				if tag[1] == '/' {
+					text = max(text-1, 0)
+				} else {
+					text++
+				}
+			}
+		case isSpace(c):
+			start := i
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			// whitespace between tags is only rendered in text
+			if text > 0 || (start > 0 && s[start-1] != '>') || (i < len(s) && s[i] != '<') {
+				out = append(out, ' ')
+			}
+		default:
+			out = append(out, c)
+			i++
+		}
+	}
+	return bytes.TrimSpace(out), nil
+}
+
+// svgTextElement returns the name of the text element tag opens or closes.
+func svgTextElement(tag []byte) string {
+	name := strings.TrimPrefix(string(tag[1:]), "/")
+	for _, element := range svgTextElements {
+		if strings.HasPrefix(name, element) && len(name) > len(element) && (isSpace(name[len(element)]) || name[len(element)] == '>') {
+			return element
+		}
+	}
+	return ""
+}
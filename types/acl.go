@@ -1,23 +1,57 @@
 package types
 
-import "strings"
+import (
+	"fmt"
+	"strings"
 
+	"gopkg.in/yaml.v3"
+)
+
+// ObjectACL is the canned ACL applied to uploaded objects.
 type ObjectACL string
 
-func (o *ObjectACL) UnmarshalYAML(unmarshal func(interface{}) error) error {
+const (
+	PrivateACL                ObjectACL = "private"
+	PublicReadACL             ObjectACL = "public-read"
+	AuthenticatedReadACL      ObjectACL = "authenticated-read"
+	BucketOwnerFullControlACL ObjectACL = "bucket-owner-full-control"
+	// NoACL sends no ACL header at all, the object gets the bucket default.
+	NoACL ObjectACL = "none"
+)
+
+// ObjectACLs lists every supported ACL.
+var ObjectACLs = []ObjectACL{PrivateACL, PublicReadACL, AuthenticatedReadACL, BucketOwnerFullControlACL, NoACL}
+
+// ParseObjectACL converts s to an ObjectACL, rejecting unknown values so a
+// typo never makes objects public. "public" is accepted for public-read as
+// it was the only public ACL of earlier releases.
+func ParseObjectACL(s string) (ObjectACL, error) {
+	value := ObjectACL(strings.ToLower(strings.TrimSpace(s)))
+	if value == "public" {
+		return PublicReadACL, nil
+	}
+	for _, acl := range ObjectACLs {
+		if value == acl {
+			return acl, nil
+		}
+	}
+
+	names := make([]string, len(ObjectACLs))
+	for i, acl := range ObjectACLs {
+		names[i] = string(acl)
+	}
+	return "", fmt.Errorf("unknown acl %q, expected one of %s", s, strings.Join(names, ", "))
+}
+
+func (o *ObjectACL) UnmarshalYAML(value *yaml.Node) error {
 	var s string
-	if err := unmarshal(&s); err != nil {
+	if err := value.Decode(&s); err != nil {
 		return err
 	}
-	if strings.ToLower(s) == "private" {
-		*o = PrivateACL
-	} else {
-		*o = PublicACL
+	acl, err := ParseObjectACL(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", value.Line, err)
 	}
+	*o = acl
 	return nil
 }
-
-const (
-	PrivateACL ObjectACL = "private"
-	PublicACL  ObjectACL = "public"
-)
@@ -0,0 +1,39 @@
+package types
+
+// WebsiteConfig is the static website hosting configuration of a bucket.
+type WebsiteConfig struct {
+	IndexDocument string
+	ErrorDocument string
+	RoutingRules  []RoutingRule
+}
+
+// RoutingRule redirects requests matching its condition.
+type RoutingRule struct {
+	Condition *RoutingCondition `yaml:"condition,omitempty"`
+	Redirect  RoutingRedirect   `yaml:"redirect"`
+}
+
+// RoutingCondition matches requests by key prefix, error code or both.
+type RoutingCondition struct {
+	KeyPrefixEquals             string `yaml:"key-prefix-equals,omitempty"`
+	HTTPErrorCodeReturnedEquals string `yaml:"http-error-code-returned-equals,omitempty"`
+}
+
+// RoutingRedirect is where a matched request is redirected to. At most one
+// of ReplaceKeyPrefixWith and ReplaceKeyWith may be set.
+type RoutingRedirect struct {
+	HostName             string `yaml:"host-name,omitempty"`
+	HTTPRedirectCode     string `yaml:"http-redirect-code,omitempty"`
+	Protocol             string `yaml:"protocol,omitempty"`
+	ReplaceKeyPrefixWith string `yaml:"replace-key-prefix-with,omitempty"`
+	ReplaceKeyWith       string `yaml:"replace-key-with,omitempty"`
+}
+
+// CORSRule allows cross-origin requests to the bucket.
+type CORSRule struct {
+	AllowedOrigins []string `yaml:"allowed-origins"`
+	AllowedMethods []string `yaml:"allowed-methods"`
+	AllowedHeaders []string `yaml:"allowed-headers,omitempty"`
+	ExposeHeaders  []string `yaml:"expose-headers,omitempty"`
+	MaxAgeSeconds  int      `yaml:"max-age-seconds,omitempty"`
+}
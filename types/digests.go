@@ -0,0 +1,39 @@
+package types
+
+type HashAlgorithm string
+
+const (
+	MD5    HashAlgorithm = "md5"
+	SHA256 HashAlgorithm = "sha256"
+	CRC32C HashAlgorithm = "crc32c"
+)
+
+// Digests holds the base64 encoded digests of a file. Only the algorithms
+// that were requested are set.
+type Digests struct {
+	MD5    string `json:",omitempty"`
+	SHA256 string `json:",omitempty"`
+	CRC32C string `json:",omitempty"`
+}
+
+func (d Digests) Get(algorithm HashAlgorithm) string {
+	switch algorithm {
+	case MD5:
+		return d.MD5
+	case SHA256:
+		return d.SHA256
+	case CRC32C:
+		return d.CRC32C
+	}
+	return ""
+}
+
+// Has reports whether every algorithm in algorithms has a digest.
+func (d Digests) Has(algorithms ...HashAlgorithm) bool {
+	for _, algorithm := range algorithms {
+		if d.Get(algorithm) == "" {
+			return false
+		}
+	}
+	return true
+}
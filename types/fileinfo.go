@@ -10,4 +10,19 @@ type FileInfo struct {
 	SourcePath   string
 	TargetPath   string
 	FileType     FileType
+	Size         int64
+	// ChecksumAlgorithm and Checksum are the additional digest sent with
+	// the upload, empty when no checksum algorithm is configured.
+	ChecksumAlgorithm HashAlgorithm
+	Checksum          string
+	// RedirectLocation makes the file an empty object redirecting to this
+	// path, relative to the deploy prefix. SourcePath is then the file the
+	// redirect was created for, its content is not uploaded.
+	RedirectLocation string
+	// Content replaces the file at SourcePath when set, for files rewritten
+	// before they are uploaded. Size is then its length.
+	Content []byte
+	// MinifiedBytes is the number of bytes minification removed from
+	// Content.
+	MinifiedBytes int64
 }
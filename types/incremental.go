@@ -9,6 +9,13 @@ type IncrementalConfigValue struct {
 	ContentMD5   string
 	CacheControl string
 	ContentType  string
+	ETag         string `json:",omitempty"`
+	Size         int64  `json:",omitempty"`
+
+	ChecksumAlgorithm HashAlgorithm `json:",omitempty"`
+	Checksum          string        `json:",omitempty"`
+	ACL               ObjectACL     `json:",omitempty"`
+	RedirectLocation  string        `json:",omitempty"`
 }
 
 type IncrementalConfig struct {
@@ -16,6 +23,18 @@ type IncrementalConfig struct {
 	M map[string]IncrementalConfigValue
 }
 
+func incrementalConfigValue(file FileInfo) IncrementalConfigValue {
+	return IncrementalConfigValue{
+		ContentMD5:        file.ContentMD5,
+		CacheControl:      file.CacheControl,
+		ContentType:       file.ContentType,
+		ChecksumAlgorithm: file.ChecksumAlgorithm,
+		Checksum:          file.Checksum,
+		ACL:               file.ACL,
+		RedirectLocation:  file.RedirectLocation,
+	}
+}
+
 func NewIncrementalConfig() *IncrementalConfig {
 	return &IncrementalConfig{
 		M: make(map[string]IncrementalConfigValue),
@@ -25,10 +44,19 @@ func NewIncrementalConfig() *IncrementalConfig {
 func IncrementalConfigFromFileInfos(files []FileInfo) *IncrementalConfig {
 	i := NewIncrementalConfig()
 	for _, file := range files {
-		i.M[file.TargetPath] = IncrementalConfigValue{
-			ContentMD5:   file.ContentMD5,
-			CacheControl: file.CacheControl,
-			ContentType:  file.ContentType,
+		i.M[file.TargetPath] = incrementalConfigValue(file)
+	}
+	return i
+}
+
+// IncrementalConfigFromObjectInfos builds the remote state from a bucket
+// listing. Only ETag and Size are known, so ContentMD5 is left empty.
+func IncrementalConfigFromObjectInfos(objects []ObjectInfo) *IncrementalConfig {
+	i := NewIncrementalConfig()
+	for _, obj := range objects {
+		i.M[obj.Key] = IncrementalConfigValue{
+			ETag: obj.ETag,
+			Size: obj.Size,
 		}
 	}
 	return i
@@ -46,11 +74,7 @@ func (i *IncrementalConfig) Set(file FileInfo) {
 	i.Lock()
 	defer i.Unlock()
 
-	i.M[file.TargetPath] = IncrementalConfigValue{
-		ContentMD5:   file.ContentMD5,
-		CacheControl: file.CacheControl,
-		ContentType:  file.ContentType,
-	}
+	i.M[file.TargetPath] = incrementalConfigValue(file)
 }
 
 func (i *IncrementalConfig) Delete(file FileInfo) {
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type FileType string
 
 const (
@@ -8,3 +10,22 @@ const (
 	PDF   FileType = "pdf"
 	Other FileType = "other"
 )
+
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	// ContentType and CacheControl are only populated by HeadObject, a
+	// listing does not carry object metadata.
+	ContentType  string
+	CacheControl string
+}
+
+type ObjectVersion struct {
+	Key            string
+	VersionID      string
+	LastModified   time.Time
+	IsLatest       bool
+	IsDeleteMarker bool
+}
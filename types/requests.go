@@ -10,4 +10,38 @@ type PutObjectRequest struct {
 	ContentType  string
 	CacheControl string
 	ACL          ObjectACL
+	// ContentMD5 is the base64 encoded MD5 of Body, sent so the backend
+	// rejects a body corrupted in transit.
+	ContentMD5 string
+	// ChecksumAlgorithm and Checksum add a stronger base64 encoded digest of
+	// Body which is verified by the backend and in its response.
+	ChecksumAlgorithm HashAlgorithm
+	Checksum          string
+	// WebsiteRedirectLocation redirects website requests for the object.
+	WebsiteRedirectLocation string
+}
+
+// CopyObjectRequest restores an older version of an object as its current
+// version.
+type CopyObjectRequest struct {
+	Key       string
+	VersionID string
+	ACL       ObjectACL
+}
+
+type UploadPartRequest struct {
+	Key               string
+	UploadID          string
+	PartNumber        int32
+	Body              io.ReadSeeker
+	Size              int64
+	ContentMD5        string
+	ChecksumAlgorithm HashAlgorithm
+	Checksum          string
+}
+
+type CompletedPart struct {
+	PartNumber int32
+	ETag       string
+	Checksum   string
 }
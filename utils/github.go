@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// PullRequestNumber reads the number of the pull request that triggered the
+// workflow from the event payload at GITHUB_EVENT_PATH.
+func PullRequestNumber() (int, error) {
+	path := os.Getenv("GITHUB_EVENT_PATH")
+	if path == "" {
+		return 0, fmt.Errorf("GITHUB_EVENT_PATH is not set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var event struct {
+		Number      int `json:"number"`
+		PullRequest struct {
+			Number int `json:"number"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return 0, fmt.Errorf("Error reading event payload: %v", err)
+	}
+	if event.PullRequest.Number > 0 {
+		return event.PullRequest.Number, nil
+	}
+	if event.Number > 0 {
+		return event.Number, nil
+	}
+	return 0, fmt.Errorf("the workflow was not triggered by a pull request")
+}
+
+// Workflow describes the workflow run the action runs in, read from the
+// default environment variables of the runner. Fields are empty outside of
+// a workflow.
+type Workflow struct {
+	Repository string `json:"repository,omitempty"`
+	SHA        string `json:"sha,omitempty"`
+	Ref        string `json:"ref,omitempty"`
+	RunURL     string `json:"run_url,omitempty"`
+}
+
+// CurrentWorkflow returns the workflow run of the environment.
+func CurrentWorkflow() Workflow {
+	workflow := Workflow{
+		Repository: os.Getenv("GITHUB_REPOSITORY"),
+		SHA:        os.Getenv("GITHUB_SHA"),
+		Ref:        os.Getenv("GITHUB_REF"),
+	}
+	server, runID := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_RUN_ID")
+	if server != "" && workflow.Repository != "" && runID != "" {
+		workflow.RunURL = fmt.Sprintf("%s/%s/actions/runs/%s", server, workflow.Repository, runID)
+	}
+	return workflow
+}
@@ -2,29 +2,137 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
+	"strings"
+
+	"github.com/rizaldntr/storage-service-website-action/types"
 )
 
-func HashMD5(filepath string) (string, error) {
+// ETagFromMD5 converts a base64 encoded MD5 digest into the hex form S3
+// reports as the ETag of an object uploaded with a single PUT.
+func ETagFromMD5(md5 string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(md5)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// IsMultipartETag reports whether etag was produced by a multipart upload,
+// e.g. "d41d8cd98f00b204e9800998ecf8427e-3".
+func IsMultipartETag(etag string) bool {
+	return strings.Contains(etag, "-")
+}
+
+// MultipartETagParts returns the number of parts encoded in a multipart ETag.
+func MultipartETagParts(etag string) int {
+	i := strings.LastIndex(etag, "-")
+	if i < 0 {
+		return 0
+	}
+	var parts int
+	if _, err := fmt.Sscanf(etag[i+1:], "%d", &parts); err != nil {
+		return 0
+	}
+	return parts
+}
+
+// MultipartETag computes the ETag S3 assigns to a file uploaded in parts of
+// partSize bytes: the MD5 of the concatenated part MD5s, suffixed with the
+// number of parts.
+func MultipartETag(filepath string, partSize int64) (string, error) {
 	f, err := os.Open(filepath)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
-	return hashMD5(f)
+	return multipartETag(f, partSize)
+}
 
+func multipartETag(r io.Reader, partSize int64) (string, error) {
+	if partSize <= 0 {
+		return "", fmt.Errorf("invalid part size %d", partSize)
+	}
+
+	sums := md5.New()
+	parts := 0
+	for {
+		h := md5.New()
+		n, err := io.CopyN(h, r, partSize)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if n == 0 && parts > 0 {
+			break
+		}
+		sums.Write(h.Sum(nil))
+		parts++
+		if n < partSize {
+			break
+		}
+	}
+
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sums.Sum(nil)), parts), nil
 }
 
-func hashMD5(f io.Reader) (string, error) {
-	h := md5.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// HashFile computes the digests of every algorithm in algorithms while
+// reading the file only once.
+func HashFile(filepath string, algorithms ...types.HashAlgorithm) (types.Digests, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return types.Digests{}, err
+	}
+	defer f.Close()
+	return HashReader(f, algorithms...)
+}
+
+// HashReader is HashFile for an arbitrary reader.
+func HashReader(r io.Reader, algorithms ...types.HashAlgorithm) (types.Digests, error) {
+	hashes := make(map[types.HashAlgorithm]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		if _, ok := hashes[algorithm]; ok {
+			continue
+		}
+		var h hash.Hash
+		switch algorithm {
+		case types.MD5:
+			h = md5.New()
+		case types.SHA256:
+			h = sha256.New()
+		case types.CRC32C:
+			h = crc32.New(crc32cTable)
+		default:
+			return types.Digests{}, fmt.Errorf("unsupported hash algorithm %q", algorithm)
+		}
+		hashes[algorithm] = h
+		writers = append(writers, h)
 	}
 
-	result := h.Sum(nil)
-	encoded := base64.StdEncoding.EncodeToString(result)
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return types.Digests{}, err
+	}
 
-	return encoded, nil
+	var digests types.Digests
+	for algorithm, h := range hashes {
+		encoded := base64.StdEncoding.EncodeToString(h.Sum(nil))
+		switch algorithm {
+		case types.MD5:
+			digests.MD5 = encoded
+		case types.SHA256:
+			digests.SHA256 = encoded
+		case types.CRC32C:
+			digests.CRC32C = encoded
+		}
+	}
+	return digests, nil
 }
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package utils
+
+import "io/fs"
+
+// Inode returns the inode number of info, or 0 when it is unknown.
+func Inode(info fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by concurrent callers. Callers that
+// find the bucket empty go into debt and sleep until it is paid back, which
+// keeps the long-term rate at the configured limit. A nil *RateLimiter does
+// not limit anything.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing rate tokens per second with
+// bursts of up to burst tokens, or nil when rate is not positive.
+func NewRateLimiter(rate float64, burst float64) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a single token is available.
+func (l *RateLimiter) Wait() {
+	l.WaitN(1)
+}
+
+// WaitN blocks until n tokens are available. Requests larger than the burst
+// are split so a single caller cannot starve the others.
+func (l *RateLimiter) WaitN(n int64) {
+	if l == nil {
+		return
+	}
+	for n > 0 {
+		take := min(float64(n), l.burst)
+		time.Sleep(l.reserve(take))
+		n -= int64(take)
+	}
+}
+
+func (l *RateLimiter) reserve(n float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= n
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// LimitReader throttles reads from r using l. The result keeps supporting
+// Seek when r does, so request bodies can still be rewound on retries.
+func LimitReader(r io.Reader, l *RateLimiter) io.Reader {
+	if l == nil || r == nil {
+		return r
+	}
+	if s, ok := r.(io.ReadSeeker); ok {
+		return &limitedReadSeeker{limitedReader{r: s, limiter: l}, s}
+	}
+	return &limitedReader{r: r, limiter: l}
+}
+
+// LimitReadSeeker is LimitReader for bodies that must stay seekable.
+func LimitReadSeeker(r io.ReadSeeker, l *RateLimiter) io.ReadSeeker {
+	if l == nil || r == nil {
+		return r
+	}
+	return &limitedReadSeeker{limitedReader{r: r, limiter: l}, r}
+}
+
+type limitedReader struct {
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.limiter.WaitN(int64(n))
+	return n, err
+}
+
+type limitedReadSeeker struct {
+	limitedReader
+	seeker io.Seeker
+}
+
+func (l *limitedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return l.seeker.Seek(offset, whence)
+}